@@ -0,0 +1,149 @@
+package sloggorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Budget limits the statements executed with a context, see WithQueryBudget. Zero values are unlimited.
+type Budget struct {
+	MaxStatements int           // max number of statements
+	MaxDuration   time.Duration // max cumulative DB time
+	MaxRows       int64         // max cumulative rows affected or returned
+}
+
+// BudgetExceededError is returned for the statements executed once the budget of their context is exceeded,
+// when the companion Plugin is in strict mode, see Plugin.WithStrictBudget
+type BudgetExceededError struct {
+	Budget     Budget
+	Statements int
+	Duration   time.Duration
+	Rows       int64
+}
+
+func (e *BudgetExceededError) Error() string {
+	var usage []string
+	if e.Budget.MaxStatements > 0 && e.Statements > e.Budget.MaxStatements {
+		usage = append(usage, fmt.Sprintf("%d statements (max %d)", e.Statements, e.Budget.MaxStatements))
+	}
+	if e.Budget.MaxDuration > 0 && e.Duration > e.Budget.MaxDuration {
+		usage = append(usage, fmt.Sprintf("%s DB time (max %s)", e.Duration, e.Budget.MaxDuration))
+	}
+	if e.Budget.MaxRows > 0 && e.Rows > e.Budget.MaxRows {
+		usage = append(usage, fmt.Sprintf("%d rows (max %d)", e.Rows, e.Budget.MaxRows))
+	}
+	return "sloggorm: query budget exceeded: " + strings.Join(usage, ", ")
+}
+
+type budgetKey struct{}
+
+// queryBudget accumulates the statements traced with a context against its budget
+type queryBudget struct {
+	limits Budget
+
+	mu         sync.Mutex
+	statements int
+	duration   time.Duration
+	rows       int64
+	exceeded   bool
+}
+
+// WithQueryBudget returns a copy of ctx with the given budget, e.g. per HTTP request or background job.
+//
+// The statements executed with the returned context are accumulated against the budget,
+// and a single warning is logged once it's exceeded, see WithBudgetExceededMsg.
+// In strict mode, the following statements fail with a *BudgetExceededError, see Plugin.WithStrictBudget.
+func WithQueryBudget(ctx context.Context, b Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, &queryBudget{limits: b})
+}
+
+// budgetFrom returns the query budget of the context, nil if none
+func budgetFrom(ctx context.Context) *queryBudget {
+	if ctx == nil {
+		return nil
+	}
+	b, _ := ctx.Value(budgetKey{}).(*queryBudget)
+	return b
+}
+
+// usage returns the budget usage as an error
+func (b *queryBudget) usage() *BudgetExceededError {
+	return &BudgetExceededError{Budget: b.limits, Statements: b.statements, Duration: b.duration, Rows: b.rows}
+}
+
+// err returns a *BudgetExceededError if the budget is exceeded, nil otherwise. It's safe to call on nil.
+func (b *queryBudget) err() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.exceeded {
+		return nil
+	}
+	return b.usage()
+}
+
+// add accounts a statement, it returns the usage the first time the budget is exceeded
+func (b *queryBudget) add(elapsed time.Duration, rows int64) *BudgetExceededError {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.statements++
+	b.duration += elapsed
+	if rows > 0 {
+		b.rows += rows
+	}
+	if b.exceeded {
+		return nil
+	}
+	b.exceeded = b.limits.MaxStatements > 0 && b.statements > b.limits.MaxStatements ||
+		b.limits.MaxDuration > 0 && b.duration > b.limits.MaxDuration ||
+		b.limits.MaxRows > 0 && b.rows > b.limits.MaxRows
+	if !b.exceeded {
+		return nil
+	}
+	return b.usage()
+}
+
+// traceBudget accounts a traced statement to the budget, and warns the first time it's exceeded
+func (l *logger) traceBudget(ctx context.Context, b *queryBudget, elapsed time.Duration, sql string, rows int64, file string, err error) {
+	var budgetErr *BudgetExceededError
+	if errors.As(err, &budgetErr) {
+		// the statement has not been executed
+		return
+	}
+
+	usage := b.add(elapsed, rows)
+	if usage == nil || !l.enabled(ctx, slog.LevelWarn) {
+		return
+	}
+
+	limits := make([]slog.Attr, 0, 3)
+	if usage.Budget.MaxStatements > 0 {
+		limits = append(limits, slog.Int("max_statements", usage.Budget.MaxStatements))
+	}
+	if usage.Budget.MaxDuration > 0 {
+		limits = append(limits, slog.Duration("max_duration", usage.Budget.MaxDuration))
+	}
+	if usage.Budget.MaxRows > 0 {
+		limits = append(limits, slog.Int64("max_rows", usage.Budget.MaxRows))
+	}
+
+	attrs := make([]slog.Attr, 0, 6)
+	attrs = append(attrs,
+		slog.Int("statements", usage.Statements),
+		slog.Duration("db_time", usage.Duration),
+		slog.Int64("total_rows", usage.Rows),
+		slog.Attr{Key: "budget", Value: slog.GroupValue(limits...)},
+	)
+	attrs = l.appendSource(attrs, file)
+	if l.queryKey != "" {
+		attrs = append(attrs, slog.String(l.queryKey, sql))
+	}
+	l.log(ctx, slog.LevelWarn, l.budgetExceededMsg, l.recordAttrs(ctx, attrs)...)
+}
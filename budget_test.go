@@ -0,0 +1,88 @@
+package sloggorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithQueryBudget(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()))
+	db := openTestDB(t, NewPlugin(l))
+	require.NoError(t, db.Create(&[]testUser{{Name: "a"}, {Name: "b"}, {Name: "c"}}).Error)
+	buf.Reset()
+
+	t.Run("max statements", func(t *testing.T) {
+		ctx := WithQueryBudget(context.Background(), Budget{MaxStatements: 2})
+		for i := 0; i < 4; i++ {
+			var users []testUser
+			require.NoError(t, db.WithContext(ctx).Where("age = ?", i+10).Find(&users).Error)
+		}
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "WARN", records[0]["level"])
+		assert.Equal(t, "Query budget EXCEEDED", records[0]["msg"])
+		assert.Equal(t, float64(3), records[0]["statements"])
+		assert.Equal(t, float64(0), records[0]["total_rows"])
+		assert.Equal(t, map[string]any{"max_statements": float64(2)}, records[0]["budget"])
+		assert.Equal(t, "SELECT * FROM `test_users` WHERE age = 12", records[0]["query"])
+	})
+
+	t.Run("max rows", func(t *testing.T) {
+		ctx := WithQueryBudget(context.Background(), Budget{MaxRows: 4, MaxDuration: time.Hour})
+		var users []testUser
+		require.NoError(t, db.WithContext(ctx).Find(&users).Error)
+		assert.Empty(t, buf.records(t))
+		require.NoError(t, db.WithContext(ctx).Find(&users).Error)
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, float64(6), records[0]["total_rows"])
+		assert.Equal(t, map[string]any{"max_rows": float64(4), "max_duration": float64(time.Hour)}, records[0]["budget"])
+	})
+
+	t.Run("not strict", func(t *testing.T) {
+		ctx := WithQueryBudget(context.Background(), Budget{MaxStatements: 1})
+		var count int64
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, db.WithContext(ctx).Model(&testUser{}).Count(&count).Error)
+		}
+		assert.Len(t, buf.records(t), 1)
+	})
+}
+
+func TestPlugin_WithStrictBudget(t *testing.T) {
+	var buf logBuffer
+	db := openTestDB(t, NewPlugin(NewWithConfig(NewConfig(buf.handler()))).WithStrictBudget(true))
+	buf.Reset()
+
+	ctx := WithQueryBudget(context.Background(), Budget{MaxStatements: 1})
+	require.NoError(t, db.WithContext(ctx).Create(&testCompany{Name: "a"}).Error)
+	assert.Empty(t, buf.records(t))
+	// the statement exceeding the budget is executed
+	require.NoError(t, db.WithContext(ctx).Create(&testCompany{Name: "b"}).Error)
+	assert.Len(t, buf.records(t), 1)
+
+	err := db.WithContext(ctx).Create(&testCompany{Name: "c"}).Error
+	var budgetErr *BudgetExceededError
+	require.True(t, errors.As(err, &budgetErr), err)
+	assert.Equal(t, 2, budgetErr.Statements)
+	assert.Equal(t, "sloggorm: query budget exceeded: 2 statements (max 1)", budgetErr.Error())
+	assert.Error(t, db.WithContext(ctx).Exec("DELETE FROM test_companies").Error)
+
+	// gorm only traces the statements with SQL, i.e. the raw one
+	records := buf.records(t)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Query ERROR", records[0]["msg"])
+	assert.Equal(t, "DELETE FROM test_companies", records[0]["query"])
+
+	var count int64
+	require.NoError(t, db.Model(&testCompany{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
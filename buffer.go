@@ -0,0 +1,123 @@
+package sloggorm
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// bufferedRecord is an OK-query record held by a query scope, see WithTailBuffer
+type bufferedRecord struct {
+	ctx     context.Context
+	handler slog.Handler
+	record  slog.Record
+}
+
+// MarkFailed marks the query scope of the context as failed, see WithQueryScope.
+//
+// In tail buffering mode, the records held by the scope are flushed, and the following ones are logged right away, see WithTailBuffer.
+func MarkFailed(ctx context.Context) {
+	s := scopeFrom(ctx)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	l := s.logger
+	s.mu.Unlock()
+	s.fail(ctx, l)
+}
+
+// buffer holds an OK-query record in the scope, unless it has failed already. It returns false if the record is not held.
+func (l *logger) buffer(ctx context.Context, s *queryScope, attrs []slog.Attr) bool {
+	var pcs [1]uintptr
+	// skip [runtime.Callers, this function, this function's caller]
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, l.okMsg, pcs[0])
+	r.AddAttrs(attrs...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed {
+		return false
+	}
+	if len(s.buffered) >= l.tailBufferSize {
+		// drop the oldest record, the most recent ones are the most relevant to the failure
+		copy(s.buffered, s.buffered[1:])
+		s.buffered = s.buffered[:len(s.buffered)-1]
+		s.dropped++
+		if l.expvars != nil {
+			l.expvars.bufferDropped.Add(1)
+		}
+	}
+	s.buffered = append(s.buffered, bufferedRecord{ctx: ctx, handler: l.slogHandler, record: r})
+	return true
+}
+
+// fail marks the scope as failed and flushes the buffered records, reporting the dropped ones with the given logger if any
+func (s *queryScope) fail(ctx context.Context, l *logger) {
+	s.mu.Lock()
+	if s.failed {
+		s.mu.Unlock()
+		return
+	}
+	s.failed = true
+	buffered, dropped := s.buffered, s.dropped
+	s.buffered, s.dropped = nil, 0
+	s.mu.Unlock()
+
+	if dropped > 0 && l != nil && l.enabled(ctx, slog.LevelWarn) {
+		l.log(ctx, slog.LevelWarn, l.bufferDroppedMsg, l.recordAttrs(ctx, []slog.Attr{slog.Int("dropped", dropped)})...)
+	}
+	for _, b := range buffered {
+		_ = b.handler.Handle(b.ctx, b.record)
+	}
+}
+
+// discard drops the buffered records of the scope
+func (s *queryScope) discard() {
+	s.mu.Lock()
+	s.buffered, s.dropped = nil, 0
+	s.mu.Unlock()
+}
+
+// statusWriter captures the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the buffered data to the client if the original writer supports it, e.g. for server-sent events
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack takes over the connection if the original writer supports it, e.g. for websockets
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the original writer, see http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
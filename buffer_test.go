@@ -0,0 +1,152 @@
+package sloggorm
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTailBuffer(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithTailBuffer(2))
+	db := openTestDB(t, NewPlugin(l))
+	buf.Reset()
+
+	t.Run("discarded", func(t *testing.T) {
+		ctx, finish := StartScope(context.Background())
+		require.NoError(t, db.WithContext(ctx).Create(&testCompany{Name: "a"}).Error)
+		assert.Empty(t, buf.records(t))
+
+		finish()
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "Query SUMMARY", records[0]["msg"])
+	})
+
+	t.Run("flushed on error", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		for _, name := range []string{"a", "b", "c"} {
+			require.NoError(t, db.WithContext(ctx).Create(&testCompany{Name: name}).Error)
+		}
+		assert.Empty(t, buf.records(t))
+
+		assert.Error(t, db.WithContext(ctx).Exec("SELECT * FROM missing").Error)
+		require.NoError(t, db.WithContext(ctx).Create(&testCompany{Name: "d"}).Error)
+
+		records := buf.records(t)
+		require.Len(t, records, 5)
+		assert.Equal(t, "Query buffer DROPPED", records[0]["msg"])
+		assert.Equal(t, float64(1), records[0]["dropped"])
+		assert.Equal(t, "Query OK", records[1]["msg"])
+		assert.Contains(t, records[1]["query"], `"b"`)
+		assert.Equal(t, "Query OK", records[2]["msg"])
+		assert.Contains(t, records[2]["query"], `"c"`)
+		assert.Equal(t, "Query ERROR", records[3]["msg"])
+		// logged right away once failed
+		assert.Equal(t, "Query OK", records[4]["msg"])
+		assert.Contains(t, records[4]["query"], `"d"`)
+	})
+
+	t.Run("marked failed", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		var companies []testCompany
+		require.NoError(t, db.WithContext(ctx).Find(&companies).Error)
+		assert.Empty(t, buf.records(t))
+
+		MarkFailed(ctx)
+		MarkFailed(ctx)
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "Query OK", records[0]["msg"])
+	})
+
+	t.Run("without scope", func(t *testing.T) {
+		require.NoError(t, db.Create(&testCompany{Name: "e"}).Error)
+		assert.Empty(t, buf.records(t))
+		MarkFailed(context.Background())
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var company testCompany
+			db.WithContext(r.Context()).First(&company)
+			if r.URL.Path == "/fail" {
+				http.Error(w, "oops", http.StatusInternalServerError)
+			}
+		}))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "Query SUMMARY", records[0]["msg"])
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		records = buf.records(t)
+		require.Len(t, records, 2)
+		assert.Equal(t, "Query OK", records[0]["msg"])
+		assert.Equal(t, "Query SUMMARY", records[1]["msg"])
+
+		assert.Panics(t, func() {
+			Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var company testCompany
+				db.WithContext(r.Context()).First(&company)
+				panic("oops")
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+		records = buf.records(t)
+		require.Len(t, records, 2)
+		assert.Equal(t, "Query OK", records[0]["msg"])
+	})
+}
+
+// hijackRecorder is a response recorder supporting hijacking
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return r.conn, bufio.NewReadWriter(bufio.NewReader(r.conn), bufio.NewWriter(r.conn)), nil
+}
+
+func TestMiddleware_writer(t *testing.T) {
+	t.Run("flusher", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f, ok := w.(http.Flusher)
+			require.True(t, ok)
+			_, _ = w.Write([]byte("data: a\n\n"))
+			f.Flush()
+		})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.True(t, rec.Flushed)
+		assert.Equal(t, "data: a\n\n", rec.Body.String())
+	})
+
+	t.Run("hijacker", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer client.Close()
+		rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: server}
+		Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h, ok := w.(http.Hijacker)
+			require.True(t, ok)
+			conn, _, err := h.Hijack()
+			require.NoError(t, err)
+			assert.Same(t, server, conn)
+		})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	t.Run("hijacking not supported", func(t *testing.T) {
+		Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _, err := w.(http.Hijacker).Hijack()
+			assert.ErrorIs(t, err, http.ErrNotSupported)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
@@ -3,6 +3,7 @@ package sloggorm
 import (
 	"context"
 	"log/slog"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,7 @@ func NewConfig(h slog.Handler) *config {
 		parameterizedQueries:      false,
 		silent:                    false,
 		traceAll:                  false,
+		traceTransactions:         false,
 		contextKeys:               map[string]any{},
 		contextExtractor:          nil,
 		groupKey:                  "",
@@ -27,10 +29,58 @@ func NewConfig(h slog.Handler) *config {
 		durationKey:               "duration",
 		rowsKey:                   "rows",
 		sourceKey:                 "file",
+		operationKey:              "",
+		tableKey:                  "",
+		modelKey:                  "model",
+		clausesKey:                "clauses",
+		dialectKey:                "dialect",
+		dryRunKey:                 "dry_run",
+		txIDKey:                   "tx_id",
+		poolWaitKey:               "pool_wait",
+		causeKey:                  "cause",
+		deadlineKey:               "deadline_remaining",
 		fullSourcePath:            false,
+		nPlusOneThreshold:         10,
+		duplicateQueries:          true,
+		tailBufferSize:            0,
+		flightRecorder:            nil,
+		flightDumpRule:            nil,
+		stats:                     nil,
+		observers:                 nil,
+		expvars:                   nil,
+		lintRules:                 nil,
+		largeTables:               nil,
+		wideTables:                nil,
+		rowsThreshold:             0,
+		operationRowsThresholds:   nil,
+		tableRowsThresholds:       nil,
+		optimisticLockColumns:     nil,
+		canceledLevel:             slog.LevelInfo,
+		timeoutLevel:              slog.LevelWarn,
 		okMsg:                     "Query OK",
 		slowMsg:                   "Query SLOW",
 		errorMsg:                  "Query ERROR",
+		canceledMsg:               "Query CANCELED",
+		timeoutMsg:                "Query TIMEOUT",
+		txBeginMsg:                "Transaction BEGIN",
+		txCommitMsg:               "Transaction COMMIT",
+		txRollbackMsg:             "Transaction ROLLBACK",
+		runningMsg:                "Query RUNNING",
+		txRunningMsg:              "Transaction RUNNING",
+		nPlusOneMsg:               "Query N+1",
+		budgetExceededMsg:         "Query budget EXCEEDED",
+		summaryMsg:                "Query SUMMARY",
+		bufferDroppedMsg:          "Query buffer DROPPED",
+		flightDumpMsg:             "Flight recorder DUMP",
+		recordedMsg:               "Query RECORDED",
+		duplicateMsg:              "Query DUPLICATE",
+		reportMsg:                 "Query REPORT",
+		poolAlertMsg:              "Pool ALERT",
+		poolSummaryMsg:            "Pool SUMMARY",
+		explainMsg:                "Query EXPLAIN",
+		lintMsg:                   "Query LINT",
+		rowsExceededMsg:           "Query ROWS EXCEEDED",
+		staleUpdateMsg:            "Query STALE UPDATE",
 	}
 }
 
@@ -43,6 +93,7 @@ type config struct {
 	parameterizedQueries      bool
 	silent                    bool
 	traceAll                  bool
+	traceTransactions         bool
 
 	contextKeys      map[string]any
 	contextExtractor func(ctx context.Context) []slog.Attr
@@ -54,11 +105,64 @@ type config struct {
 	durationKey      string
 	rowsKey          string
 	sourceKey        string
+	operationKey     string
+	tableKey         string
+	modelKey         string
+	clausesKey       string
+	dialectKey       string
+	dryRunKey        string
+	txIDKey          string
+	poolWaitKey      string
+	causeKey         string
+	deadlineKey      string
 	fullSourcePath   bool
 
-	okMsg    string
-	slowMsg  string
-	errorMsg string
+	nPlusOneThreshold int
+	duplicateQueries  bool
+	tailBufferSize    int
+	flightRecorder    *flightRecorder
+	flightDumpRule    func(err error) bool
+	stats             *queryStats
+	observers         []Observer
+	expvars           *expvarCounters
+	lintRules         map[string]bool
+	largeTables       map[string]bool
+	wideTables        map[string]bool
+
+	rowsThreshold           int64
+	operationRowsThresholds map[string]int64
+	tableRowsThresholds     map[string]int64
+	optimisticLockColumns   map[string]bool
+
+	canceledLevel slog.Level
+	timeoutLevel  slog.Level
+
+	okMsg       string
+	slowMsg     string
+	errorMsg    string
+	canceledMsg string
+	timeoutMsg  string
+
+	txBeginMsg    string
+	txCommitMsg   string
+	txRollbackMsg string
+	runningMsg    string
+	txRunningMsg  string
+	nPlusOneMsg   string
+
+	budgetExceededMsg string
+	summaryMsg        string
+	bufferDroppedMsg  string
+	flightDumpMsg     string
+	recordedMsg       string
+	duplicateMsg      string
+	reportMsg         string
+	poolAlertMsg      string
+	poolSummaryMsg    string
+	explainMsg        string
+	lintMsg           string
+	rowsExceededMsg   string
+	staleUpdateMsg    string
 }
 
 // clone returns a new config with same values
@@ -101,6 +205,14 @@ func (c *config) WithTraceAll(v bool) *config {
 	return c
 }
 
+// WithTraceTransactions whether to log the begin, commit and rollback of transactions. Default false.
+//
+// It's only available with the companion Plugin.
+func (c *config) WithTraceTransactions(v bool) *config {
+	c.traceTransactions = v
+	return c
+}
+
 // WithContextKeys to add custom log attributes from context by given keys
 //
 // Map keys are the attribute name, and map values are the context keys to extract with ctx.Value()
@@ -157,12 +269,169 @@ func (c *config) WithSourceKey(v string) *config {
 	return c
 }
 
+// WithOperationKey set name for the SQL operation attribute, e.g. SELECT, INSERT, DDL. Default is empty, i.e. not included
+func (c *config) WithOperationKey(v string) *config {
+	c.operationKey = v
+	return c
+}
+
+// WithTableKey set name for the attribute listing the primary table followed by joined tables. Default is empty, i.e. not included
+func (c *config) WithTableKey(v string) *config {
+	c.tableKey = v
+	return c
+}
+
+// WithModelKey set different name for the model type attribute, set empty value to drop it. Default "model".
+//
+// It's only available with the companion Plugin, so are the clauses, dialect and dry run attributes.
+func (c *config) WithModelKey(v string) *config {
+	c.modelKey = v
+	return c
+}
+
+// WithClausesKey set different name for the attribute listing the clauses used, set empty value to drop it. Default "clauses"
+func (c *config) WithClausesKey(v string) *config {
+	c.clausesKey = v
+	return c
+}
+
+// WithDialectKey set different name for the dialector name attribute, set empty value to drop it. Default "dialect"
+func (c *config) WithDialectKey(v string) *config {
+	c.dialectKey = v
+	return c
+}
+
+// WithDryRunKey set different name for the dry run attribute, which is only added in dry run mode, set empty value to drop it. Default "dry_run"
+func (c *config) WithDryRunKey(v string) *config {
+	c.dryRunKey = v
+	return c
+}
+
+// WithTxIDKey set different name for the transaction ID attribute, set empty value to drop it. Default "tx_id".
+//
+// It's only available with the companion Plugin.
+func (c *config) WithTxIDKey(v string) *config {
+	c.txIDKey = v
+	return c
+}
+
+// WithPoolWaitKey set different name for the time spent waiting for a connection, set empty value to drop it. Default "pool_wait".
+//
+// It's only available with the companion Plugin, see Plugin.WithPoolWait.
+func (c *config) WithPoolWaitKey(v string) *config {
+	c.poolWaitKey = v
+	return c
+}
+
+// WithCauseKey set different name for the cancellation cause of the context, when it's not the context error itself, set empty value to drop it. Default "cause"
+func (c *config) WithCauseKey(v string) *config {
+	c.causeKey = v
+	return c
+}
+
+// WithDeadlineKey set different name for the time remaining before the context deadline when the statement started, set empty value to drop it.
+// Default "deadline_remaining".
+//
+// It's only available with the companion Plugin.
+func (c *config) WithDeadlineKey(v string) *config {
+	c.deadlineKey = v
+	return c
+}
+
 // WithFullSourcePath whether to include full path in source attribute or just the file name. Default false
 func (c *config) WithFullSourcePath(v bool) *config {
 	c.fullSourcePath = v
 	return c
 }
 
+// WithNPlusOneThreshold set the number of times the same normalized query can run within a query scope before being reported as N+1,
+// set zero to disable. Default 10.
+//
+// See WithQueryScope to start a scope.
+func (c *config) WithNPlusOneThreshold(v int) *config {
+	c.nPlusOneThreshold = v
+	return c
+}
+
+// WithDuplicateQueries whether to report the queries executed again with the same params within a query scope,
+// as a caching opportunity. Default true.
+//
+// See WithQueryScope to start a scope.
+func (c *config) WithDuplicateQueries(v bool) *config {
+	c.duplicateQueries = v
+	return c
+}
+
+// WithTailBuffer enables tail-based buffering of OK queries within a query scope, holding up to the given number of records. Default 0, i.e. disabled.
+//
+// The records are flushed when the scope sees a failed query or is marked as failed, see MarkFailed, otherwise they are discarded.
+// When the buffer is full, the oldest records are dropped, and reported once flushed.
+func (c *config) WithTailBuffer(size int) *config {
+	c.tailBufferSize = size
+	return c
+}
+
+// WithFlightRecorder enables an in-memory ring buffer of the last given number of traced statements, whatever their level. Default 0, i.e. disabled.
+//
+// The statements are kept as fingerprints, without params, see FlightRecords, DumpFlightRecorder and FlightRecorderHandler.
+// The loggers derived from this config, e.g. with gorm's Session or Debug, share the same recorder.
+func (c *config) WithFlightRecorder(size int) *config {
+	c.flightRecorder = nil
+	if size > 0 {
+		c.flightRecorder = newFlightRecorder(size)
+	}
+	return c
+}
+
+// WithFlightRecorderDumpOn set the rule to dump the flight recorder when a statement fails, e.g. errors.Is(err, driver.ErrBadConn). Default nil, i.e. never
+func (c *config) WithFlightRecorderDumpOn(rule func(err error) bool) *config {
+	c.flightDumpRule = rule
+	return c
+}
+
+// WithStats whether to aggregate the statistics of the traced statements by fingerprint, whatever their level. Default false.
+//
+// See Stats and ResetStats to inspect them. The loggers derived from this config share the same statistics.
+func (c *config) WithStats(v bool) *config {
+	c.stats = nil
+	if v {
+		c.stats = newQueryStats()
+	}
+	return c
+}
+
+// WithObservers set the observers notified of every traced statement, whatever its level, e.g. to export metrics. Default none
+func (c *config) WithObservers(v ...Observer) *config {
+	c.observers = v
+	return c
+}
+
+// WithExpvar publish the running counters of the logger as an expvar map with the given name, shown by /debug/vars:
+// statements by outcome, errors by class, rows, DB time and dropped records. The loggers with the same name share the counters.
+// It panics if the name is used by another expvar variable. Default not published
+func (c *config) WithExpvar(name string) *config {
+	c.expvars = publishExpvar(name)
+	return c
+}
+
+// WithLintRules set the lint rules warning about the risky statements as they run, see LintRules. Default none
+func (c *config) WithLintRules(v ...string) *config {
+	c.lintRules = toSet(v, false)
+	return c
+}
+
+// WithLargeTables set the tables which must not be read without LIMIT, see LintSelectWithoutLimit. Default none
+func (c *config) WithLargeTables(v ...string) *config {
+	c.largeTables = toSet(v, true)
+	return c
+}
+
+// WithWideTables set the tables which must not be read with SELECT *, see LintSelectStar. Default none
+func (c *config) WithWideTables(v ...string) *config {
+	c.wideTables = toSet(v, true)
+	return c
+}
+
 // WithOkMsg changes log message for successful query. Default "Query OK"
 func (c *config) WithOkMsg(v string) *config {
 	c.okMsg = v
@@ -180,3 +449,192 @@ func (c *config) WithErrorMsg(v string) *config {
 	c.errorMsg = v
 	return c
 }
+
+// WithCanceledMsg changes log message for query failed because its context was canceled. Default "Query CANCELED"
+func (c *config) WithCanceledMsg(v string) *config {
+	c.canceledMsg = v
+	return c
+}
+
+// WithTimeoutMsg changes log message for query failed because its context deadline was exceeded. Default "Query TIMEOUT"
+func (c *config) WithTimeoutMsg(v string) *config {
+	c.timeoutMsg = v
+	return c
+}
+
+// WithCanceledLevel set the log level of the queries failed because their context was canceled, e.g. the client went away. Default INFO
+func (c *config) WithCanceledLevel(v slog.Level) *config {
+	c.canceledLevel = v
+	return c
+}
+
+// WithTimeoutLevel set the log level of the queries failed because their context deadline was exceeded. Default WARN
+func (c *config) WithTimeoutLevel(v slog.Level) *config {
+	c.timeoutLevel = v
+	return c
+}
+
+// WithTxBeginMsg changes log message for transaction begin. Default "Transaction BEGIN"
+func (c *config) WithTxBeginMsg(v string) *config {
+	c.txBeginMsg = v
+	return c
+}
+
+// WithTxCommitMsg changes log message for transaction commit. Default "Transaction COMMIT"
+func (c *config) WithTxCommitMsg(v string) *config {
+	c.txCommitMsg = v
+	return c
+}
+
+// WithTxRollbackMsg changes log message for transaction rollback, including rollback to a savepoint. Default "Transaction ROLLBACK"
+func (c *config) WithTxRollbackMsg(v string) *config {
+	c.txRollbackMsg = v
+	return c
+}
+
+// WithRunningMsg changes log message for statement still running, see Plugin.WithWatchdog. Default "Query RUNNING"
+func (c *config) WithRunningMsg(v string) *config {
+	c.runningMsg = v
+	return c
+}
+
+// WithTxRunningMsg changes log message for transaction still open, see Plugin.WithWatchdog. Default "Transaction RUNNING"
+func (c *config) WithTxRunningMsg(v string) *config {
+	c.txRunningMsg = v
+	return c
+}
+
+// WithNPlusOneMsg changes log message for N+1 queries, see WithNPlusOneThreshold. Default "Query N+1"
+func (c *config) WithNPlusOneMsg(v string) *config {
+	c.nPlusOneMsg = v
+	return c
+}
+
+// WithBudgetExceededMsg changes log message for query budget exceeded, see WithQueryBudget. Default "Query budget EXCEEDED"
+func (c *config) WithBudgetExceededMsg(v string) *config {
+	c.budgetExceededMsg = v
+	return c
+}
+
+// WithSummaryMsg changes log message for the summary of a query scope, see StartScope. Default "Query SUMMARY"
+func (c *config) WithSummaryMsg(v string) *config {
+	c.summaryMsg = v
+	return c
+}
+
+// WithBufferDroppedMsg changes log message reporting the records dropped from a full buffer, see WithTailBuffer. Default "Query buffer DROPPED"
+func (c *config) WithBufferDroppedMsg(v string) *config {
+	c.bufferDroppedMsg = v
+	return c
+}
+
+// WithFlightDumpMsg changes log message preceding the statements of a flight recorder dump, see WithFlightRecorder. Default "Flight recorder DUMP"
+func (c *config) WithFlightDumpMsg(v string) *config {
+	c.flightDumpMsg = v
+	return c
+}
+
+// WithRecordedMsg changes log message for the statements of a flight recorder dump, see WithFlightRecorder. Default "Query RECORDED"
+func (c *config) WithRecordedMsg(v string) *config {
+	c.recordedMsg = v
+	return c
+}
+
+// WithDuplicateMsg changes log message for duplicate queries, see WithDuplicateQueries. Default "Query DUPLICATE"
+func (c *config) WithDuplicateMsg(v string) *config {
+	c.duplicateMsg = v
+	return c
+}
+
+// WithReportMsg changes log message for the periodic report of the top queries, see NewReporter. Default "Query REPORT"
+func (c *config) WithReportMsg(v string) *config {
+	c.reportMsg = v
+	return c
+}
+
+// WithRowsThreshold set the max number of rows a SELECT returns, or an UPDATE or DELETE affects, without a warning. Default 0, i.e. disabled
+func (c *config) WithRowsThreshold(v int64) *config {
+	c.rowsThreshold = v
+	return c
+}
+
+// WithOperationRowsThresholds set the rows thresholds by operation, e.g. OpSelect, overriding WithRowsThreshold. Default none
+func (c *config) WithOperationRowsThresholds(v map[string]int64) *config {
+	c.operationRowsThresholds = toThresholds(v, strings.ToUpper)
+	return c
+}
+
+// WithTableRowsThresholds set the rows thresholds by primary table, overriding the ones by operation. 0 disables the warning for the table. Default none
+func (c *config) WithTableRowsThresholds(v map[string]int64) *config {
+	c.tableRowsThresholds = toThresholds(v, strings.ToLower)
+	return c
+}
+
+// WithOptimisticLockColumns set the version columns of the optimistic locking, an UPDATE affecting no rows with one of them in its WHERE clause is warned as stale. Default none
+func (c *config) WithOptimisticLockColumns(v ...string) *config {
+	c.optimisticLockColumns = toSet(v, true)
+	return c
+}
+
+// WithPoolAlertMsg changes log message for a connection pool crossing a threshold, see NewPoolMonitor. Default "Pool ALERT"
+func (c *config) WithPoolAlertMsg(v string) *config {
+	c.poolAlertMsg = v
+	return c
+}
+
+// WithExplainMsg changes log message for the plan of a slow SELECT, see Plugin.WithAutoExplain. Default "Query EXPLAIN"
+func (c *config) WithExplainMsg(v string) *config {
+	c.explainMsg = v
+	return c
+}
+
+// WithLintMsg changes log message for a statement violating a lint rule, see WithLintRules. Default "Query LINT"
+func (c *config) WithLintMsg(v string) *config {
+	c.lintMsg = v
+	return c
+}
+
+// WithRowsExceededMsg changes log message for a statement returning or affecting more rows than its threshold, see WithRowsThreshold. Default "Query ROWS EXCEEDED"
+func (c *config) WithRowsExceededMsg(v string) *config {
+	c.rowsExceededMsg = v
+	return c
+}
+
+// WithStaleUpdateMsg changes log message for an optimistic-lock UPDATE affecting no rows, see WithOptimisticLockColumns. Default "Query STALE UPDATE"
+func (c *config) WithStaleUpdateMsg(v string) *config {
+	c.staleUpdateMsg = v
+	return c
+}
+
+// WithPoolSummaryMsg changes log message for the periodic summary of a connection pool, see NewPoolMonitor. Default "Pool SUMMARY"
+func (c *config) WithPoolSummaryMsg(v string) *config {
+	c.poolSummaryMsg = v
+	return c
+}
+
+// toSet returns the values as a set, lowercased if fold, or nil if empty
+func toSet(values []string, fold bool) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if fold {
+			v = strings.ToLower(v)
+		}
+		set[v] = true
+	}
+	return set
+}
+
+// toThresholds copies the thresholds with normalized keys, nil if empty
+func toThresholds(values map[string]int64, normalize func(string) string) map[string]int64 {
+	if len(values) == 0 {
+		return nil
+	}
+	thresholds := make(map[string]int64, len(values))
+	for k, v := range values {
+		thresholds[normalize(k)] = v
+	}
+	return thresholds
+}
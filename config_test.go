@@ -17,18 +17,51 @@ func TestNewConfig(t *testing.T) {
 		var cfg *config
 		assert.NotPanics(t, func() { cfg = NewConfig(slog.Default().Handler()) })
 		assert.Equal(t, &config{
-			slogHandler:      slog.Default().Handler(),
-			slowThreshold:    200 * time.Millisecond,
-			errorKey:         "error",
-			slowThresholdKey: "slow_threshold",
-			contextKeys:      map[string]any{},
-			queryKey:         "query",
-			durationKey:      "duration",
-			rowsKey:          "rows",
-			sourceKey:        "file",
-			okMsg:            "Query OK",
-			slowMsg:          "Query SLOW",
-			errorMsg:         "Query ERROR",
+			slogHandler:       slog.Default().Handler(),
+			slowThreshold:     200 * time.Millisecond,
+			errorKey:          "error",
+			slowThresholdKey:  "slow_threshold",
+			contextKeys:       map[string]any{},
+			queryKey:          "query",
+			durationKey:       "duration",
+			rowsKey:           "rows",
+			sourceKey:         "file",
+			modelKey:          "model",
+			clausesKey:        "clauses",
+			dialectKey:        "dialect",
+			dryRunKey:         "dry_run",
+			txIDKey:           "tx_id",
+			poolWaitKey:       "pool_wait",
+			causeKey:          "cause",
+			deadlineKey:       "deadline_remaining",
+			nPlusOneThreshold: 10,
+			duplicateQueries:  true,
+			okMsg:             "Query OK",
+			slowMsg:           "Query SLOW",
+			errorMsg:          "Query ERROR",
+			canceledMsg:       "Query CANCELED",
+			timeoutMsg:        "Query TIMEOUT",
+			canceledLevel:     slog.LevelInfo,
+			timeoutLevel:      slog.LevelWarn,
+			txBeginMsg:        "Transaction BEGIN",
+			txCommitMsg:       "Transaction COMMIT",
+			txRollbackMsg:     "Transaction ROLLBACK",
+			runningMsg:        "Query RUNNING",
+			txRunningMsg:      "Transaction RUNNING",
+			nPlusOneMsg:       "Query N+1",
+			budgetExceededMsg: "Query budget EXCEEDED",
+			summaryMsg:        "Query SUMMARY",
+			bufferDroppedMsg:  "Query buffer DROPPED",
+			flightDumpMsg:     "Flight recorder DUMP",
+			recordedMsg:       "Query RECORDED",
+			duplicateMsg:      "Query DUPLICATE",
+			reportMsg:         "Query REPORT",
+			poolAlertMsg:      "Pool ALERT",
+			poolSummaryMsg:    "Pool SUMMARY",
+			explainMsg:        "Query EXPLAIN",
+			lintMsg:           "Query LINT",
+			rowsExceededMsg:   "Query ROWS EXCEEDED",
+			staleUpdateMsg:    "Query STALE UPDATE",
 		}, cfg)
 	})
 }
@@ -0,0 +1,201 @@
+package sloggorm
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// connPool wraps the gorm connection pool to track the transactions and the SQL of in-flight statements
+type connPool struct {
+	gorm.ConnPool
+	plugin *Plugin
+}
+
+// ensure our connPool implements the interfaces checked by gorm
+var (
+	_ gorm.ConnPoolBeginner = (*connPool)(nil)
+	_ gorm.GetDBConnector   = (*connPool)(nil)
+)
+
+// wrapConnPool wraps the connection pool of db, keeping gorm's prepared statement pool on top if any
+func wrapConnPool(db *gorm.DB, p *Plugin) {
+	if pdb, ok := db.ConnPool.(*gorm.PreparedStmtDB); ok {
+		if _, wrapped := pdb.ConnPool.(*connPool); !wrapped {
+			pdb.ConnPool = &connPool{ConnPool: pdb.ConnPool, plugin: p}
+		}
+	} else if _, wrapped := db.ConnPool.(*connPool); !wrapped {
+		db.ConnPool = &connPool{ConnPool: db.ConnPool, plugin: p}
+	}
+	db.Statement.ConnPool = db.ConnPool
+}
+
+// BeginTx starts a tracked transaction
+func (c *connPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var (
+		tx  gorm.ConnPool
+		err error
+	)
+	switch beginner := c.ConnPool.(type) {
+	case gorm.TxBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	default:
+		return nil, gorm.ErrInvalidTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gtx, ok := tx.(gorm.Tx)
+	if !ok {
+		// not a regular transaction, leave it untracked
+		return tx, nil
+	}
+	return &txConn{Tx: gtx, pool: c, info: c.plugin.beginTx(ctx)}, nil
+}
+
+// PrepareContext prepares a statement
+func (c *connPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	setInflightQuery(ctx, query, nil, nil)
+	return c.ConnPool.PrepareContext(ctx, query)
+}
+
+// ExecContext executes a statement
+func (c *connPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	setInflightQuery(ctx, query, args, nil)
+	return c.ConnPool.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query
+func (c *connPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	setInflightQuery(ctx, query, args, nil)
+	return c.ConnPool.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query returning at most one row
+func (c *connPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	setInflightQuery(ctx, query, args, nil)
+	return c.ConnPool.QueryRowContext(ctx, query, args...)
+}
+
+// GetDBConn returns the underlying *sql.DB, see gorm.DB.DB()
+func (c *connPool) GetDBConn() (*sql.DB, error) {
+	if sqlDB, ok := c.ConnPool.(*sql.DB); ok {
+		return sqlDB, nil
+	}
+	if connector, ok := c.ConnPool.(gorm.GetDBConnector); ok {
+		return connector.GetDBConn()
+	}
+	return nil, gorm.ErrInvalidDB
+}
+
+// txConn wraps a gorm transaction to track its statements and lifecycle
+type txConn struct {
+	gorm.Tx
+	pool *connPool
+	info *txInfo
+}
+
+// ensure our txConn implements the interfaces checked by gorm
+var (
+	_ gorm.Tx             = (*txConn)(nil)
+	_ gorm.GetDBConnector = (*txConn)(nil)
+)
+
+// PrepareContext prepares a statement within the transaction
+func (t *txConn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	setInflightQuery(ctx, query, nil, t.info)
+	return t.Tx.PrepareContext(ctx, query)
+}
+
+// ExecContext executes a statement within the transaction
+func (t *txConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	setInflightQuery(ctx, query, args, t.info)
+	defer t.track(ctx, time.Now())
+	if name, rollback, ok := parseSavepoint(query); ok {
+		defer t.pool.plugin.savepoint(t.info, name, rollback)
+	}
+	return t.Tx.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query within the transaction
+func (t *txConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	setInflightQuery(ctx, query, args, t.info)
+	defer t.track(ctx, time.Now())
+	return t.Tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query within the transaction
+func (t *txConn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	setInflightQuery(ctx, query, args, t.info)
+	defer t.track(ctx, time.Now())
+	return t.Tx.QueryRowContext(ctx, query, args...)
+}
+
+// StmtContext returns a transaction-specific prepared statement, used by gorm's prepared statement mode.
+// The statement is executed right after, it's timed until the end of its callbacks, see txInfo.startPrepared.
+func (t *txConn) StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	if info := stmtInfoFrom(ctx); info != nil && info.stmt != nil {
+		// the prepared statement hides its SQL, the one built by gorm is executed
+		query := info.stmt.SQL.String()
+		setInflightQuery(ctx, query, info.stmt.Vars, t.info)
+		if name, rollback, ok := parseSavepoint(query); ok {
+			defer t.pool.plugin.savepoint(t.info, name, rollback)
+		}
+		info.tx = t.info
+	}
+	t.info.startPrepared(time.Now())
+	return t.Tx.StmtContext(ctx, stmt)
+}
+
+// Commit commits the transaction
+func (t *txConn) Commit() error {
+	t.info.endPrepared(time.Now())
+	err := t.Tx.Commit()
+	t.pool.plugin.endTx(t.info, false, err)
+	return err
+}
+
+// Rollback aborts the transaction
+func (t *txConn) Rollback() error {
+	t.info.endPrepared(time.Now())
+	err := t.Tx.Rollback()
+	t.pool.plugin.endTx(t.info, true, err)
+	return err
+}
+
+// GetDBConn returns the underlying *sql.DB, see gorm.DB.DB()
+func (t *txConn) GetDBConn() (*sql.DB, error) {
+	return t.pool.GetDBConn()
+}
+
+// track accounts a statement started at the given time to the transaction
+func (t *txConn) track(ctx context.Context, start time.Time) {
+	t.info.addStatement(time.Since(start))
+	if info := stmtInfoFrom(ctx); info != nil {
+		info.tx = t.info
+	}
+}
+
+// parseSavepoint parses the savepoint name of SAVEPOINT and ROLLBACK TO [SAVEPOINT] statements
+func parseSavepoint(query string) (name string, rollback bool, ok bool) {
+	query = strings.TrimSpace(query)
+	if len(query) < len("SAVEPOINT") ||
+		!strings.EqualFold(query[:len("SAVEPOINT")], "SAVEPOINT") && !strings.EqualFold(query[:len("ROLLBACK")], "ROLLBACK") {
+		return "", false, false
+	}
+
+	tokens := tokenizeSQL(query)
+	switch {
+	case len(tokens) == 2 && tokens[0].is("SAVEPOINT"):
+		return unquoteIdent(tokens[1].text), false, true
+	case len(tokens) >= 3 && tokens[0].is("ROLLBACK") && tokens[1].is("TO"):
+		return unquoteIdent(tokens[len(tokens)-1].text), true, true
+	}
+	return "", false, false
+}
@@ -0,0 +1,168 @@
+package sloggorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxExplainFingerprints is the max number of fingerprints rate-limited, the new ones are skipped beyond until the oldest expire
+	maxExplainFingerprints = 1000
+	// maxConcurrentExplains is the max number of EXPLAINs running at once, the next ones are skipped not to compete with the app for connections
+	maxConcurrentExplains = 2
+)
+
+// explainPrefixes are the EXPLAIN statements by dialector name
+var explainPrefixes = map[string]string{
+	"sqlite":     "EXPLAIN QUERY PLAN ",
+	"mysql":      "EXPLAIN ",
+	"postgres":   "EXPLAIN ",
+	"clickhouse": "EXPLAIN ",
+}
+
+// explainer runs the EXPLAIN of the slow SELECTs in the background, see Plugin.WithAutoExplain
+type explainer struct {
+	db       *sql.DB
+	prefix   string
+	interval time.Duration
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time // the time of the last EXPLAIN by fingerprint
+	sem  chan struct{}        // the EXPLAINs running
+	wg   sync.WaitGroup
+}
+
+func newExplainer(db *sql.DB, dialect string, interval, timeout time.Duration) (*explainer, error) {
+	prefix, ok := explainPrefixes[dialect]
+	if !ok {
+		return nil, fmt.Errorf("sloggorm: auto-EXPLAIN is not supported by the %q dialect", dialect)
+	}
+	return &explainer{
+		db:       db,
+		prefix:   prefix,
+		interval: interval,
+		timeout:  timeout,
+		last:     map[string]time.Time{},
+		sem:      make(chan struct{}, maxConcurrentExplains),
+	}, nil
+}
+
+// allow reports whether the fingerprint can be explained now, and records it if so
+func (e *explainer) allow(fingerprint string, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if last, ok := e.last[fingerprint]; ok && now.Sub(last) < e.interval {
+		return false
+	}
+	if len(e.last) >= maxExplainFingerprints {
+		// forget the fingerprints which are not rate-limited anymore
+		for fp, last := range e.last {
+			if now.Sub(last) >= e.interval {
+				delete(e.last, fp)
+			}
+		}
+		if len(e.last) >= maxExplainFingerprints {
+			return false
+		}
+	}
+	e.last[fingerprint] = now
+	return true
+}
+
+// plan runs the EXPLAIN of the statement, returning a line per row
+func (e *explainer) plan(ctx context.Context, query string, vars []any) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	rows, err := e.db.QueryContext(ctx, e.prefix+query, vars...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var plan []string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		switch {
+		case e.prefix == explainPrefixes["sqlite"] || len(cols) == 1:
+			// the detail column of SQLite, or the single line of a textual plan
+			plan = append(plan, values[len(values)-1].String)
+		default:
+			fields := make([]string, 0, len(cols))
+			for i, col := range cols {
+				if values[i].Valid {
+					fields = append(fields, col+"="+values[i].String)
+				}
+			}
+			plan = append(plan, strings.Join(fields, " "))
+		}
+	}
+	return plan, rows.Err()
+}
+
+// explain logs the plan of a slow SELECT in the background, at most once per fingerprint per interval
+func (l *logger) explain(ctx context.Context, info *stmtInfo, elapsed time.Duration, sql string) {
+	e := info.explainer
+	if info.dryRun || info.sql == "" || info.sqlInfo(sql).operation != OpSelect {
+		return
+	}
+	select {
+	case e.sem <- struct{}{}:
+	default:
+		// too many EXPLAINs running already, e.g. the database is slow
+		return
+	}
+	fp := info.fingerprint(sql)
+	if !e.allow(fp, time.Now()) {
+		<-e.sem
+		return
+	}
+
+	query, vars := info.sql, append([]any(nil), info.stmt.Vars...)
+	ctx = context.WithoutCancel(ctx)
+	e.wg.Add(1)
+	go func() {
+		defer func() {
+			<-e.sem
+			e.wg.Done()
+		}()
+		plan, err := e.plan(ctx, query, vars)
+		if !l.enabled(ctx, slog.LevelWarn) {
+			return
+		}
+
+		attrs := make([]slog.Attr, 0, 4)
+		if l.durationKey != "" {
+			attrs = append(attrs, slog.Duration(l.durationKey, elapsed))
+		}
+		attrs = append(attrs, slog.String("fingerprint", fp))
+		if err != nil {
+			if l.errorKey != "" {
+				attrs = append(attrs, slog.Any(l.errorKey, err))
+			}
+		} else {
+			attrs = append(attrs, slog.Any("plan", plan))
+		}
+		if l.queryKey != "" {
+			attrs = append(attrs, slog.String(l.queryKey, sql))
+		}
+		l.log(ctx, slog.LevelWarn, l.explainMsg, l.recordAttrs(ctx, attrs)...)
+	}()
+}
@@ -0,0 +1,123 @@
+package sloggorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPlugin_WithAutoExplain(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithSlowThreshold(time.Nanosecond).WithContextKeys(map[string]any{"req_id": ctxKey("req_id")}))
+	p := NewPlugin(l).WithAutoExplain(time.Hour).WithExplainTimeout(time.Second)
+	db := openTestDB(t, p)
+	require.NoError(t, db.Create(&testCompany{Name: "a"}).Error)
+	require.NoError(t, p.Close())
+	buf.Reset()
+
+	ctx := context.WithValue(context.Background(), ctxKey("req_id"), "123")
+	var companies []testCompany
+	require.NoError(t, db.WithContext(ctx).Where("name = ?", "a").Find(&companies).Error)
+	require.NoError(t, db.WithContext(ctx).Where("name = ?", "b").Find(&companies).Error) // rate-limited
+	var company testCompany
+	require.NoError(t, db.WithContext(ctx).First(&company, 1).Error)
+	require.NoError(t, db.WithContext(ctx).Model(&company).Update("name", "c").Error) // not a SELECT
+	require.NoError(t, p.Close())
+
+	var plans []map[string]any
+	for _, r := range buf.records(t) {
+		if r["msg"] == "Query EXPLAIN" {
+			plans = append(plans, r)
+		}
+	}
+	require.Len(t, plans, 2)
+	byFingerprint := map[string]map[string]any{}
+	for _, r := range plans {
+		assert.Equal(t, "WARN", r["level"])
+		assert.Equal(t, "123", r["req_id"])
+		assert.Contains(t, r, "duration")
+		byFingerprint[r["fingerprint"].(string)] = r
+	}
+
+	scan := byFingerprint["SELECT * FROM `test_companies` WHERE name = ?"]
+	require.NotNil(t, scan)
+	assert.Equal(t, []any{"SCAN test_companies"}, scan["plan"])
+	assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = \"a\"", scan["query"])
+
+	search := byFingerprint["SELECT * FROM `test_companies` WHERE `test_companies`.`id` = ? ORDER BY `test_companies`.`id` LIMIT ?"]
+	require.NotNil(t, search)
+	assert.Equal(t, []any{"SEARCH test_companies USING INTEGER PRIMARY KEY (rowid=?)"}, search["plan"])
+
+	t.Run("failed EXPLAIN", func(t *testing.T) {
+		info := &stmtInfo{stmt: &gorm.Statement{}, explainer: p.explainer, sql: "SELECT * FROM missing"}
+		l.explain(ctx, info, time.Second, "SELECT * FROM missing")
+		require.NoError(t, p.Close())
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "Query EXPLAIN", records[0]["msg"])
+		assert.Contains(t, records[0]["error"], "no such table: missing")
+		assert.NotContains(t, records[0], "plan")
+	})
+
+	t.Run("unsupported dialect", func(t *testing.T) {
+		_, err := newExplainer(nil, "sqlserver", time.Minute, time.Second)
+		assert.EqualError(t, err, `sloggorm: auto-EXPLAIN is not supported by the "sqlserver" dialect`)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		require.NoError(t, db.Session(&gorm.Session{DryRun: true}).Where("age > ?", 1).Find(&companies).Error)
+		require.NoError(t, p.Close())
+		for _, r := range buf.records(t) {
+			assert.NotEqual(t, "Query EXPLAIN", r["msg"])
+		}
+	})
+}
+
+func Test_explainer_allow(t *testing.T) {
+	e := &explainer{interval: time.Minute, last: map[string]time.Time{}}
+	now := time.Now()
+	assert.True(t, e.allow("a", now))
+	assert.False(t, e.allow("a", now.Add(59*time.Second)))
+	assert.True(t, e.allow("b", now))
+	assert.True(t, e.allow("a", now.Add(time.Minute)))
+
+	t.Run("full", func(t *testing.T) {
+		e := &explainer{interval: time.Minute, last: map[string]time.Time{}}
+		for i := 0; i < maxExplainFingerprints-1; i++ {
+			require.True(t, e.allow(string(rune(i+'a')), now))
+		}
+		require.True(t, e.allow("last", now.Add(30*time.Second)))
+
+		// the rate limits are kept, the new fingerprints are skipped
+		assert.False(t, e.allow("new", now.Add(time.Second)))
+		assert.False(t, e.allow("a", now.Add(time.Second)))
+		assert.Len(t, e.last, maxExplainFingerprints)
+
+		// only the expired ones are forgotten
+		assert.True(t, e.allow("new", now.Add(time.Minute)))
+		assert.Len(t, e.last, 2)
+		assert.False(t, e.allow("last", now.Add(time.Minute)))
+	})
+}
+
+func Test_logger_explain_busy(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()))
+	e, err := newExplainer(nil, "sqlite", time.Minute, time.Second)
+	require.NoError(t, err)
+	for i := 0; i < maxConcurrentExplains; i++ {
+		e.sem <- struct{}{}
+	}
+
+	info := &stmtInfo{stmt: &gorm.Statement{}, explainer: e, sql: "SELECT * FROM users"}
+	l.explain(context.Background(), info, time.Second, "SELECT * FROM users")
+	e.wg.Wait()
+	assert.Empty(t, buf.records(t))
+	// skipped, not rate-limited
+	assert.Empty(t, e.last)
+}
@@ -0,0 +1,65 @@
+package sloggorm
+
+import (
+	"expvar"
+	"sync"
+)
+
+// expvarRegistry holds the published counters by name, shared by the loggers publishing under the same name
+var expvarRegistry = struct {
+	sync.Mutex
+	counters map[string]*expvarCounters
+}{counters: map[string]*expvarCounters{}}
+
+// expvarCounters are the running counters of the loggers, published as an expvar map, see WithExpvar
+type expvarCounters struct {
+	queries       *expvar.Map // by outcome
+	errors        *expvar.Map // by class
+	rows          *expvar.Int
+	dbTime        *expvar.Float // in seconds
+	bufferDropped *expvar.Int
+	statsDropped  *expvar.Int
+}
+
+// publishExpvar returns the counters published under the given name, publishing them on first use.
+// It panics if the name is used by another expvar variable.
+func publishExpvar(name string) *expvarCounters {
+	expvarRegistry.Lock()
+	defer expvarRegistry.Unlock()
+	if c, ok := expvarRegistry.counters[name]; ok {
+		return c
+	}
+
+	c := &expvarCounters{
+		queries:       new(expvar.Map),
+		errors:        new(expvar.Map),
+		rows:          new(expvar.Int),
+		dbTime:        new(expvar.Float),
+		bufferDropped: new(expvar.Int),
+		statsDropped:  new(expvar.Int),
+	}
+	for _, outcome := range []string{OutcomeOK, OutcomeSlow, OutcomeError} {
+		c.queries.Add(outcome, 0)
+	}
+	m := expvar.NewMap(name)
+	m.Set("queries", c.queries)
+	m.Set("errors", c.errors)
+	m.Set("rows", c.rows)
+	m.Set("db_time_seconds", c.dbTime)
+	m.Set("buffer_dropped", c.bufferDropped)
+	m.Set("stats_dropped", c.statsDropped)
+	expvarRegistry.counters[name] = c
+	return c
+}
+
+// add counts a traced statement, the errors by class include the ignored record not found errors
+func (c *expvarCounters) add(e TraceEvent) {
+	c.queries.Add(e.Outcome, 1)
+	if e.Err != nil {
+		c.errors.Add(ErrorClass(e.Err), 1)
+	}
+	if e.Rows > 0 {
+		c.rows.Add(e.Rows)
+	}
+	c.dbTime.Add(e.Duration.Seconds())
+}
@@ -0,0 +1,87 @@
+package sloggorm
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expvarRuns numbers the expvar names, as the published variables outlive the test, e.g. with -count
+var expvarRuns atomic.Int64
+
+// expvarName returns a name which is not published yet
+func expvarName(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, expvarRuns.Add(1))
+}
+
+// expvarValues decodes the published map
+func expvarValues(t *testing.T, name string) map[string]any {
+	t.Helper()
+	v := expvar.Get(name)
+	require.NotNil(t, v)
+	m := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &m))
+	return m
+}
+
+func TestWithExpvar(t *testing.T) {
+	var buf logBuffer
+	name := expvarName("sloggorm_test")
+	l := NewWithConfig(NewConfig(buf.handler()).WithExpvar(name).WithSlowThreshold(time.Hour))
+	db := openTestDB(t, NewPlugin(l))
+
+	require.NoError(t, db.Create(&testCompany{Name: "a"}).Error)
+	var company testCompany
+	assert.Error(t, db.First(&company, 42).Error)
+	assert.Error(t, db.Exec("SELECT * FROM missing").Error)
+
+	before := expvarValues(t, name)
+	l.Trace(context.Background(), time.Now().Add(-2*time.Hour), func() (string, int64) { return "SELECT 1", 1 }, nil)
+
+	values := expvarValues(t, name)
+	queries := values["queries"].(map[string]any)
+	assert.Equal(t, before["queries"].(map[string]any)["ok"], queries["ok"])
+	assert.Equal(t, float64(1), queries["slow"])
+	assert.Equal(t, float64(2), queries["error"])
+	assert.Equal(t, map[string]any{"not_found": float64(1), "other": float64(1)}, values["errors"])
+	assert.Equal(t, before["rows"].(float64)+1, values["rows"])
+	assert.GreaterOrEqual(t, values["db_time_seconds"], float64(7200))
+	assert.Equal(t, float64(0), values["buffer_dropped"])
+	assert.Equal(t, float64(0), values["stats_dropped"])
+
+	t.Run("shared by name", func(t *testing.T) {
+		other := NewWithConfig(NewConfig(buf.handler()).WithExpvar(name).WithSilent(true))
+		other.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+		assert.Equal(t, queries["ok"].(float64)+1, expvarValues(t, name)["queries"].(map[string]any)["ok"])
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		name := expvarName("sloggorm_test_dropped")
+		l := NewWithConfig(NewConfig(buf.handler()).WithExpvar(name).WithTailBuffer(1).WithStats(true))
+		db := openTestDB(t, NewPlugin(l))
+		l.ResetStats()
+		for i := 0; i < maxStatsFingerprints; i++ {
+			l.stats.add(string(rune(i)), OpSelect, time.Millisecond, 1, false)
+		}
+		ctx, finish := StartScope(context.Background())
+		var companies []testCompany
+		db.WithContext(ctx).Find(&companies)
+		db.WithContext(ctx).Find(&companies)
+		finish()
+
+		values := expvarValues(t, name)
+		assert.Equal(t, float64(1), values["buffer_dropped"])
+		assert.Equal(t, float64(2), values["stats_dropped"])
+	})
+
+	taken := expvarName("sloggorm_test_int")
+	expvar.NewInt(taken)
+	assert.Panics(t, func() { NewConfig(buf.handler()).WithExpvar(taken) })
+}
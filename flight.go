@@ -0,0 +1,177 @@
+package sloggorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FlightRecord is a statement kept by the flight recorder, see WithFlightRecorder
+type FlightRecord struct {
+	Time        time.Time      `json:"time"`
+	Fingerprint string         `json:"fingerprint"`
+	Duration    time.Duration  `json:"duration"` // in nanoseconds
+	Rows        int64          `json:"rows"`     // -1 if unknown
+	Error       string         `json:"error,omitempty"`
+	Source      string         `json:"source,omitempty"`
+	Attrs       map[string]any `json:"attrs,omitempty"` // context attributes
+
+	err   error
+	attrs []slog.Attr
+}
+
+// flightRecorder is a ring buffer of the last traced statements
+type flightRecorder struct {
+	mu      sync.Mutex
+	records []FlightRecord
+	next    int
+	full    bool
+}
+
+func newFlightRecorder(size int) *flightRecorder {
+	return &flightRecorder{records: make([]FlightRecord, size)}
+}
+
+// add records a statement, overwriting the oldest one when full
+func (f *flightRecorder) add(r FlightRecord) {
+	f.mu.Lock()
+	f.records[f.next] = r
+	f.next++
+	if f.next == len(f.records) {
+		f.next = 0
+		f.full = true
+	}
+	f.mu.Unlock()
+}
+
+// snapshot returns the records from the oldest to the most recent, and clears them if drain is set
+func (f *flightRecorder) snapshot(drain bool) []FlightRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var records []FlightRecord
+	if f.full {
+		records = append(records, f.records[f.next:]...)
+	}
+	records = append(records, f.records[:f.next]...)
+	if drain {
+		clear(f.records)
+		f.next, f.full = 0, false
+	}
+	return records
+}
+
+// record adds a traced statement to the flight recorder, and dumps it if the error matches the dump rule
+func (l *logger) record(ctx context.Context, elapsed time.Duration, sql string, rows int64, file string, err error) {
+	r := FlightRecord{
+		Time:        time.Now(),
+		Fingerprint: stmtInfoFrom(ctx).fingerprint(sql),
+		Duration:    elapsed,
+		Rows:        rows,
+		Source:      file,
+		err:         err,
+		attrs:       l.contextAttrs(ctx),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	l.flightRecorder.add(r)
+
+	if err != nil && l.flightDumpRule != nil && l.flightDumpRule(err) {
+		l.dumpFlightRecorder(ctx, "error")
+	}
+}
+
+// FlightRecords returns the statements kept by the flight recorder, from the oldest to the most recent, see WithFlightRecorder
+func (l *logger) FlightRecords() []FlightRecord {
+	if l.flightRecorder == nil {
+		return []FlightRecord{}
+	}
+	records := l.flightRecorder.snapshot(false)
+	for i := range records {
+		records[i].Attrs = attrsMap(records[i].attrs)
+	}
+	return records
+}
+
+// DumpFlightRecorder logs the statements kept by the flight recorder, then clears them, see WithFlightRecorder
+func (l *logger) DumpFlightRecorder(ctx context.Context) {
+	l.dumpFlightRecorder(ctx, "manual")
+}
+
+// dumpFlightRecorder logs a dump record followed by the statements kept by the flight recorder, then clears them
+func (l *logger) dumpFlightRecorder(ctx context.Context, reason string) {
+	if l.flightRecorder == nil || !l.enabled(ctx, slog.LevelInfo) {
+		return
+	}
+
+	records := l.flightRecorder.snapshot(true)
+	l.log(ctx, slog.LevelInfo, l.flightDumpMsg, l.recordAttrs(ctx, []slog.Attr{
+		slog.String("reason", reason),
+		slog.Int("records", len(records)),
+	})...)
+	for _, fr := range records {
+		attrs := make([]slog.Attr, 0, 5)
+		if l.durationKey != "" {
+			attrs = append(attrs, slog.Duration(l.durationKey, fr.Duration))
+		}
+		if fr.Rows >= 0 && l.rowsKey != "" {
+			attrs = append(attrs, slog.Int64(l.rowsKey, fr.Rows))
+		}
+		if fr.Source != "" {
+			attrs = l.appendSource(attrs, fr.Source)
+		}
+		if fr.err != nil && l.errorKey != "" {
+			attrs = append(attrs, slog.Any(l.errorKey, fr.err))
+		}
+		attrs = append(attrs, slog.String("fingerprint", fr.Fingerprint))
+		if l.groupKey != "" {
+			attrs = []slog.Attr{{Key: l.groupKey, Value: slog.GroupValue(attrs...)}}
+		}
+
+		r := slog.NewRecord(fr.Time, slog.LevelInfo, l.recordedMsg, 0)
+		r.AddAttrs(fr.attrs...)
+		r.AddAttrs(attrs...)
+		_ = l.slogHandler.Handle(ctx, r)
+	}
+}
+
+// FlightRecorderHandler returns a http.Handler rendering the statements kept by the flight recorder, see WithFlightRecorder.
+//
+// It renders plain text by default, and JSON with the ?format=json query or the "Accept: application/json" header.
+func (l *logger) FlightRecorderHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.flightRecorder == nil {
+			http.Error(w, "flight recorder is disabled, see WithFlightRecorder", http.StatusNotFound)
+			return
+		}
+
+		records := l.FlightRecords()
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(records)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "%d recent statement(s)\n", len(records))
+		for _, fr := range records {
+			fmt.Fprintf(w, "\n%s %s\n  %s\n", fr.Time.Format(time.RFC3339Nano), fr.Duration, fr.Fingerprint)
+			rows := ""
+			if fr.Rows >= 0 {
+				rows = strconv.FormatInt(fr.Rows, 10)
+			}
+			source := fr.Source
+			if source != "" && !l.fullSourcePath {
+				source = path.Base(source)
+			}
+			writeTextFields(w, "rows", rows, "source", source, "error", fr.Error)
+			writeTextMap(w, "attrs", fr.Attrs)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package sloggorm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flightCtxKey struct{}
+
+func TestWithFlightRecorder(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).
+		WithFlightRecorder(3).
+		WithContextKeys(map[string]any{"req_id": flightCtxKey{}}).
+		WithFlightRecorderDumpOn(func(err error) bool {
+			return strings.Contains(err.Error(), "no such table")
+		}))
+	db := openTestDB(t, NewPlugin(l))
+	ctx := context.WithValue(context.Background(), flightCtxKey{}, "abc")
+	l.DumpFlightRecorder(ctx) // drops the migration statements
+	buf.Reset()
+
+	t.Run("ring buffer", func(t *testing.T) {
+		for _, name := range []string{"a", "b", "c", "d"} {
+			require.NoError(t, db.WithContext(ctx).Create(&testCompany{Name: name}).Error)
+		}
+		var companies []testCompany
+		require.NoError(t, db.WithContext(ctx).Where("name = ?", "secret").Find(&companies).Error)
+		assert.Empty(t, buf.records(t))
+
+		records := l.FlightRecords()
+		require.Len(t, records, 3)
+		assert.Equal(t, "INSERT INTO `test_companies` (`name`) VALUES (?) RETURNING `id`", records[0].Fingerprint)
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = ?", records[2].Fingerprint)
+		assert.Equal(t, int64(0), records[2].Rows)
+		assert.Equal(t, "flight_test.go", strings.Split(path.Base(records[2].Source), ":")[0])
+		assert.Equal(t, map[string]any{"req_id": "abc"}, records[2].Attrs)
+		assert.True(t, records[0].Time.Before(records[2].Time))
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		l.FlightRecorderHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?format=json", nil))
+		var records []FlightRecord
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &records))
+		assert.Len(t, records, 3)
+		assert.NotContains(t, rec.Body.String(), "secret")
+
+		rec = httptest.NewRecorder()
+		l.FlightRecorderHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Contains(t, rec.Body.String(), "3 recent statement(s)")
+		assert.Contains(t, rec.Body.String(), "SELECT * FROM `test_companies` WHERE name = ?\n")
+		assert.Contains(t, rec.Body.String(), "attrs: req_id=abc")
+
+		rec = httptest.NewRecorder()
+		New().FlightRecorderHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("dump on demand", func(t *testing.T) {
+		l.DumpFlightRecorder(ctx)
+		records := buf.records(t)
+		require.Len(t, records, 4)
+		assert.Equal(t, "Flight recorder DUMP", records[0]["msg"])
+		assert.Equal(t, "manual", records[0]["reason"])
+		assert.Equal(t, float64(3), records[0]["records"])
+		assert.Equal(t, "Query RECORDED", records[3]["msg"])
+		assert.Equal(t, "abc", records[3]["req_id"])
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = ?", records[3]["fingerprint"])
+		assert.Empty(t, l.FlightRecords())
+	})
+
+	t.Run("dump on error", func(t *testing.T) {
+		var companies []testCompany
+		require.NoError(t, db.WithContext(ctx).Find(&companies).Error)
+		assert.Error(t, db.WithContext(ctx).Exec("SELECT * FROM test_companies WHERE x = ?", 1).Error)
+		assert.Len(t, buf.records(t), 1) // only the error
+
+		require.Error(t, db.WithContext(ctx).Exec("SELECT * FROM missing").Error)
+		records := buf.records(t)
+		require.Len(t, records, 5)
+		assert.Equal(t, "error", records[0]["reason"])
+		assert.Equal(t, "Query RECORDED", records[3]["msg"])
+		assert.Contains(t, records[3]["error"], "no such table")
+		assert.Equal(t, "Query ERROR", records[4]["msg"])
+	})
+}
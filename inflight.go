@@ -0,0 +1,293 @@
+package sloggorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// inflight tracks the statements and transactions in progress, started through the Plugin
+type inflight struct {
+	mu      sync.Mutex
+	lastID  uint64
+	entries map[uint64]*inflightEntry
+}
+
+func newInflight() *inflight {
+	return &inflight{entries: map[uint64]*inflightEntry{}}
+}
+
+// inflightEntry is a statement or transaction in progress
+type inflightEntry struct {
+	id        uint64
+	ctx       context.Context
+	start     time.Time
+	source    string
+	operation string
+	table     string
+	tx        *txInfo // set for transactions only
+	query     atomic.Pointer[inflightQuery]
+
+	// nextWarn is the elapsed time after which the watchdog warns again, only used by the watchdog goroutine
+	nextWarn time.Duration
+}
+
+// inflightQuery is the SQL of a statement, as sent to the driver
+type inflightQuery struct {
+	sql  string
+	args []any
+	tx   *txInfo
+}
+
+// setQuery records the SQL of the statement, and the transaction it runs in if any
+func (e *inflightEntry) setQuery(sql string, args []any, tx *txInfo) {
+	e.query.Store(&inflightQuery{sql: sql, args: args, tx: tx})
+}
+
+// add starts tracking the given entry
+func (r *inflight) add(e *inflightEntry) {
+	r.mu.Lock()
+	r.lastID++
+	e.id = r.lastID
+	r.entries[e.id] = e
+	r.mu.Unlock()
+}
+
+// remove stops tracking the given entry
+func (r *inflight) remove(e *inflightEntry) {
+	r.mu.Lock()
+	delete(r.entries, e.id)
+	r.mu.Unlock()
+}
+
+// snapshot returns the entries in progress, in order of start
+func (r *inflight) snapshot() []*inflightEntry {
+	r.mu.Lock()
+	entries := make([]*inflightEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].id < entries[j].id
+	})
+	return entries
+}
+
+// setInflightQuery records the SQL sent to the driver on the in-flight entry of the statement, if any
+func setInflightQuery(ctx context.Context, sql string, args []any, tx *txInfo) {
+	if info := stmtInfoFrom(ctx); info != nil && info.inflight != nil {
+		info.inflight.setQuery(sql, args, tx)
+	}
+}
+
+// InflightSnapshot lists the statements and transactions in progress, see Plugin.Inflight
+type InflightSnapshot struct {
+	Time         time.Time             `json:"time"`
+	Statements   []InflightStatement   `json:"statements"`
+	Transactions []InflightTransaction `json:"transactions"`
+}
+
+// InflightStatement is a statement in progress
+type InflightStatement struct {
+	ID          uint64            `json:"id"`
+	Start       time.Time         `json:"start"`
+	Elapsed     time.Duration     `json:"elapsed"` // in nanoseconds
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	Operation   string            `json:"operation,omitempty"`
+	Table       string            `json:"table,omitempty"`
+	TxID        string            `json:"tx_id,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"` // goroutine labels, see runtime/pprof.Labels
+	Attrs       map[string]any    `json:"attrs,omitempty"`  // context attributes
+}
+
+// InflightTransaction is a transaction in progress
+type InflightTransaction struct {
+	ID         uint64            `json:"id"`
+	TxID       string            `json:"tx_id"`
+	Start      time.Time         `json:"start"`
+	Elapsed    time.Duration     `json:"elapsed"` // in nanoseconds
+	Statements int               `json:"statements"`
+	DBTime     time.Duration     `json:"db_time"` // in nanoseconds
+	Source     string            `json:"source,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"` // goroutine labels, see runtime/pprof.Labels
+	Attrs      map[string]any    `json:"attrs,omitempty"`  // context attributes
+}
+
+// Inflight returns the statements and transactions in progress, in order of start.
+// It's empty unless the registry is enabled with WithInflightRegistry.
+func (p *Plugin) Inflight() InflightSnapshot {
+	snapshot := InflightSnapshot{
+		Time:         time.Now(),
+		Statements:   []InflightStatement{},
+		Transactions: []InflightTransaction{},
+	}
+	if p.inflight == nil {
+		return snapshot
+	}
+
+	for _, e := range p.inflight.snapshot() {
+		labels := goroutineLabels(e.ctx)
+		attrs := attrsMap(p.logger.contextAttrs(e.ctx))
+		if e.tx != nil {
+			e.tx.mu.Lock()
+			statements, dbTime := e.tx.statements, e.tx.dbTime
+			e.tx.mu.Unlock()
+			snapshot.Transactions = append(snapshot.Transactions, InflightTransaction{
+				ID:         e.id,
+				TxID:       e.tx.id,
+				Start:      e.start,
+				Elapsed:    snapshot.Time.Sub(e.start),
+				Statements: statements,
+				DBTime:     dbTime,
+				Source:     e.source,
+				Labels:     labels,
+				Attrs:      attrs,
+			})
+			continue
+		}
+
+		s := InflightStatement{
+			ID:        e.id,
+			Start:     e.start,
+			Elapsed:   snapshot.Time.Sub(e.start),
+			Operation: e.operation,
+			Table:     e.table,
+			Source:    e.source,
+			Labels:    labels,
+			Attrs:     attrs,
+		}
+		if q := e.query.Load(); q != nil {
+			s.Fingerprint = fingerprint(q.sql)
+			if s.Operation == "" {
+				s.Operation = parseSQL(q.sql).operation
+			}
+			if q.tx != nil {
+				s.TxID = q.tx.id
+			}
+		}
+		snapshot.Statements = append(snapshot.Statements, s)
+	}
+	return snapshot
+}
+
+// InflightHandler returns a http.Handler rendering the statements and transactions in progress, similar to /debug/pprof.
+//
+// It renders plain text by default, and JSON with the ?format=json query or the "Accept: application/json" header.
+func (p *Plugin) InflightHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.inflight == nil {
+			http.Error(w, "in-flight registry is disabled, see Plugin.WithInflightRegistry", http.StatusNotFound)
+			return
+		}
+
+		snapshot := p.Inflight()
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(snapshot)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "%d statement(s) in flight, %d open transaction(s) at %s\n",
+			len(snapshot.Statements), len(snapshot.Transactions), snapshot.Time.Format(time.RFC3339))
+		for _, s := range snapshot.Statements {
+			fmt.Fprintf(w, "\nstatement #%d running for %s\n", s.ID, s.Elapsed)
+			if s.Fingerprint != "" {
+				fmt.Fprintf(w, "  %s\n", s.Fingerprint)
+			}
+			writeTextFields(w, "operation", s.Operation, "table", s.Table, "tx_id", s.TxID, "source", s.Source)
+			writeTextMap(w, "labels", s.Labels)
+			writeTextMap(w, "attrs", s.Attrs)
+		}
+		for _, tx := range snapshot.Transactions {
+			fmt.Fprintf(w, "\ntransaction #%d open for %s\n", tx.ID, tx.Elapsed)
+			writeTextFields(w, "tx_id", tx.TxID, "statements", strconv.Itoa(tx.Statements), "db_time", tx.DBTime.String(), "source", tx.Source)
+			writeTextMap(w, "labels", tx.Labels)
+			writeTextMap(w, "attrs", tx.Attrs)
+		}
+	})
+}
+
+// wantsJSON reports whether the request asks for a JSON response
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// writeTextFields writes the non-empty key/value pairs on a single line
+func writeTextFields(w io.Writer, kvs ...string) {
+	var fields []string
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if kvs[i+1] != "" {
+			fields = append(fields, kvs[i]+"="+kvs[i+1])
+		}
+	}
+	if len(fields) > 0 {
+		fmt.Fprintf(w, "  %s\n", strings.Join(fields, " "))
+	}
+}
+
+// writeTextMap writes the map entries, sorted by key, on a single line
+func writeTextMap[V any](w io.Writer, name string, m map[string]V) {
+	if len(m) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		fields[i] = fmt.Sprintf("%s=%v", k, m[k])
+	}
+	fmt.Fprintf(w, "  %s: %s\n", name, strings.Join(fields, " "))
+}
+
+// goroutineLabels returns the goroutine labels set in the context, see runtime/pprof.WithLabels
+func goroutineLabels(ctx context.Context) map[string]string {
+	var labels map[string]string
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[key] = value
+		return true
+	})
+	return labels
+}
+
+// attrsMap converts the attributes to a map, groups are converted to nested maps
+func attrsMap(attrs []slog.Attr) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(attrs))
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		if v.Kind() == slog.KindGroup {
+			if a.Key == "" {
+				for k, gv := range attrsMap(v.Group()) {
+					m[k] = gv
+				}
+				continue
+			}
+			m[a.Key] = attrsMap(v.Group())
+			continue
+		}
+		m[a.Key] = v.Any()
+	}
+	return m
+}
@@ -0,0 +1,104 @@
+package sloggorm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type inflightCtxKey struct{}
+
+func TestPlugin_Inflight(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithContextKeys(map[string]any{"req_id": inflightCtxKey{}}))
+	p := NewPlugin(l).WithInflightRegistry(true)
+	db := openTestDB(t, p)
+
+	ctx := context.WithValue(context.Background(), inflightCtxKey{}, "abc")
+	ctx = pprof.WithLabels(ctx, pprof.Labels("handler", "users"))
+
+	t.Run("statement and transaction", func(t *testing.T) {
+		var snapshot InflightSnapshot
+		require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:inflight", func(db *gorm.DB) {
+			snapshot = p.Inflight()
+		}))
+		defer func() { _ = db.Callback().Query().Remove("test:inflight") }()
+
+		tx := db.WithContext(ctx).Begin()
+		var users []testUser
+		_, _, line, _ := runtime.Caller(0)
+		require.NoError(t, tx.Where("name = ? AND age IN ?", "jinzhu", []int{18, 19}).Find(&users).Error)
+		require.NoError(t, tx.Commit().Error)
+
+		require.Len(t, snapshot.Statements, 1)
+		s := snapshot.Statements[0]
+		assert.Equal(t, "SELECT * FROM `test_users` WHERE name = ? AND age IN (?)", s.Fingerprint)
+		assert.Equal(t, OpSelect, s.Operation)
+		assert.Equal(t, "test_users", s.Table)
+		assert.Equal(t, "inflight_test.go:"+strconv.Itoa(line+1), path.Base(s.Source))
+		assert.Equal(t, map[string]string{"handler": "users"}, s.Labels)
+		assert.Equal(t, map[string]any{"req_id": "abc"}, s.Attrs)
+
+		require.Len(t, snapshot.Transactions, 1)
+		tx1 := snapshot.Transactions[0]
+		assert.Equal(t, tx1.TxID, s.TxID)
+		assert.NotEmpty(t, tx1.TxID)
+		assert.Less(t, tx1.ID, s.ID)
+		assert.Equal(t, map[string]string{"handler": "users"}, tx1.Labels)
+
+		after := p.Inflight()
+		assert.Empty(t, after.Statements)
+		assert.Empty(t, after.Transactions)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		tx := db.WithContext(ctx).Begin()
+		defer tx.Rollback()
+		tx.Create(&testCompany{Name: "gorm"})
+
+		rec := httptest.NewRecorder()
+		p.InflightHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/sql?format=json", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		var snapshot InflightSnapshot
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snapshot))
+		assert.Empty(t, snapshot.Statements)
+		require.Len(t, snapshot.Transactions, 1)
+		assert.Equal(t, 1, snapshot.Transactions[0].Statements)
+
+		req := httptest.NewRequest(http.MethodGet, "/debug/sql", nil)
+		req.Header.Set("Accept", "application/json")
+		rec = httptest.NewRecorder()
+		p.InflightHandler().ServeHTTP(rec, req)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		rec = httptest.NewRecorder()
+		p.InflightHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/sql", nil))
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+		body := rec.Body.String()
+		assert.Contains(t, body, "0 statement(s) in flight, 1 open transaction(s)")
+		assert.Contains(t, body, "statements=1")
+		assert.Contains(t, body, "labels: handler=users")
+		assert.Contains(t, body, "attrs: req_id=abc")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		p := NewPlugin(NewWithConfig(NewConfig(buf.handler())))
+		openTestDB(t, p)
+
+		rec := httptest.NewRecorder()
+		p.InflightHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/sql", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, p.Inflight().Statements)
+	})
+}
@@ -0,0 +1,221 @@
+package sloggorm
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Lint rules, see WithLintRules
+const (
+	LintUpdateWithoutWhere = "update-without-where"  // UPDATE of all the rows
+	LintDeleteWithoutWhere = "delete-without-where"  // DELETE of all the rows
+	LintSelectWithoutLimit = "select-without-limit"  // SELECT without LIMIT on a large table, see WithLargeTables
+	LintSelectStar         = "select-star"           // SELECT * on a wide table, see WithWideTables
+	LintLeadingWildcard    = "leading-wildcard-like" // LIKE '%value', which can't use an index
+	LintImplicitCrossJoin  = "implicit-cross-join"   // FROM a, b without WHERE, or JOIN without ON/USING
+)
+
+// LintRules are all the lint rules
+var LintRules = []string{
+	LintUpdateWithoutWhere,
+	LintDeleteWithoutWhere,
+	LintSelectWithoutLimit,
+	LintSelectStar,
+	LintLeadingWildcard,
+	LintImplicitCrossJoin,
+}
+
+// aggregateFuncs are the functions returning a single row without GROUP BY
+var aggregateFuncs = map[string]bool{"COUNT": true, "SUM": true, "MIN": true, "MAX": true, "AVG": true}
+
+// lintViolation is a lint rule violated by a statement, with the table concerned if any
+type lintViolation struct {
+	rule  string
+	table string
+}
+
+// traceLint warns about the lint rules violated by a statement
+func (l *logger) traceLint(ctx context.Context, sql, file string) {
+	if !l.enabled(ctx, slog.LevelWarn) {
+		return
+	}
+	for _, v := range l.lint(sql) {
+		attrs := []slog.Attr{slog.String("rule", v.rule)}
+		if l.tableKey != "" && v.table != "" {
+			attrs = append(attrs, slog.Any(l.tableKey, []string{v.table}))
+		}
+		attrs = l.appendSource(attrs, file)
+		if l.queryKey != "" {
+			attrs = append(attrs, slog.String(l.queryKey, sql))
+		}
+		l.log(ctx, slog.LevelWarn, l.lintMsg, l.recordAttrs(ctx, attrs)...)
+	}
+}
+
+// lint returns the enabled rules violated by a statement, in the order of LintRules
+func (l *logger) lint(sql string) []lintViolation {
+	tokens := tokenizeSQL(sql)
+	top := topLevel(tokens)
+	// the operation and tables of the main statement, without the subqueries
+	info := parseTokens(top)
+	has := func(keywords ...string) bool {
+		for _, t := range top {
+			for _, k := range keywords {
+				if t.is(k) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var violations []lintViolation
+	add := func(rule, table string) {
+		if l.lintRules[rule] {
+			violations = append(violations, lintViolation{rule, table})
+		}
+	}
+
+	switch info.operation {
+	case OpUpdate:
+		if !has("WHERE") {
+			add(LintUpdateWithoutWhere, firstTable(info.tables))
+		}
+	case OpDelete:
+		if !has("WHERE") {
+			add(LintDeleteWithoutWhere, firstTable(info.tables))
+		}
+	case OpSelect:
+		if table := matchTable(info.tables, l.largeTables); table != "" && !has("LIMIT", "FETCH", "TOP") && (has("GROUP") || !isAggregate(top)) {
+			add(LintSelectWithoutLimit, table)
+		}
+		if table := matchTable(info.tables, l.wideTables); table != "" && selectsStar(top) {
+			add(LintSelectStar, table)
+		}
+	}
+	if hasLeadingWildcard(tokens) {
+		add(LintLeadingWildcard, "")
+	}
+	if hasImplicitCrossJoin(top) {
+		add(LintImplicitCrossJoin, "")
+	}
+	return violations
+}
+
+// topLevel returns the top-level clauses, the parenthesized groups are reduced to their parentheses, e.g. count()
+func topLevel(tokens []sqlToken) []sqlToken {
+	var top []sqlToken
+	depth := 0
+	for _, t := range tokens {
+		switch {
+		case t.isPunct("("):
+			if depth == 0 {
+				top = append(top, t)
+			}
+			depth++
+		case t.isPunct(")"):
+			depth = max(depth-1, 0)
+			if depth == 0 {
+				top = append(top, t)
+			}
+		case depth == 0:
+			top = append(top, t)
+		}
+	}
+	return top
+}
+
+// firstTable returns the primary table, if any
+func firstTable(tables []string) string {
+	if len(tables) == 0 {
+		return ""
+	}
+	return tables[0]
+}
+
+// matchTable returns the first table in the given set, by its name or its unqualified name, case-insensitively
+func matchTable(tables []string, set map[string]bool) string {
+	for _, t := range tables {
+		name := strings.ToLower(t)
+		if set[name] || set[name[strings.LastIndexByte(name, '.')+1:]] {
+			return t
+		}
+	}
+	return ""
+}
+
+// isAggregate reports whether the top-level select list starts with an aggregate function
+func isAggregate(top []sqlToken) bool {
+	for i, t := range top {
+		if t.is("SELECT") && i+2 < len(top) {
+			return top[i+1].kind == tokWord && aggregateFuncs[strings.ToUpper(top[i+1].text)] && top[i+2].isPunct("(")
+		}
+	}
+	return false
+}
+
+// selectsStar reports whether the top-level select list has a star, e.g. SELECT * or SELECT users.*
+func selectsStar(top []sqlToken) bool {
+	inList := false
+	for _, t := range top {
+		switch {
+		case t.is("SELECT"):
+			inList = true
+		case t.is("FROM"):
+			inList = false
+		case inList && t.isPunct("*"):
+			return true
+		}
+	}
+	return false
+}
+
+// hasLeadingWildcard reports whether a LIKE pattern starts with a wildcard, the inlined params may be quoted as identifiers
+func hasLeadingWildcard(tokens []sqlToken) bool {
+	for i := 0; i+1 < len(tokens); i++ {
+		if !tokens[i].is("LIKE") && !tokens[i].is("ILIKE") {
+			continue
+		}
+		next := tokens[i+1]
+		if (next.kind == tokString || next.kind == tokQuotedIdent) && len(next.text) > 1 && (next.text[1] == '%' || next.text[1] == '_') {
+			return true
+		}
+	}
+	return false
+}
+
+// hasImplicitCrossJoin reports whether the top-level FROM clause lists several tables without WHERE, or has a JOIN without condition
+func hasImplicitCrossJoin(top []sqlToken) bool {
+	p := sqlParser{tokens: top, ctes: map[string]bool{}}
+	hasWhere, commaJoin := false, false
+	for i := 0; i < len(top); i++ {
+		t := top[i]
+		switch {
+		case t.is("WHERE"):
+			hasWhere = true
+		case t.is("FROM"):
+			_, next := p.sourceRef(i + 1)
+			next = p.skipAlias(skipGroup(top, next))
+			if next < len(top) && top[next].isPunct(",") {
+				commaJoin = true
+			}
+		case t.is("JOIN") && (i == 0 || (!top[i-1].is("CROSS") && !top[i-1].is("NATURAL"))):
+			// the condition follows the table reference and its alias
+			_, next := p.sourceRef(i + 1)
+			next = p.skipAlias(skipGroup(top, next))
+			if next >= len(top) || (!top[next].is("ON") && !top[next].is("USING")) {
+				return true
+			}
+		}
+	}
+	return commaJoin && !hasWhere
+}
+
+// skipGroup skips a reduced parenthesized group at position i, e.g. a subquery or the arguments of a function
+func skipGroup(top []sqlToken, i int) int {
+	if i+1 < len(top) && top[i].isPunct("(") && top[i+1].isPunct(")") {
+		return i + 2
+	}
+	return i
+}
@@ -0,0 +1,130 @@
+package sloggorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func Test_logger_lint(t *testing.T) {
+	l := NewWithConfig(NewConfig(New().slogHandler).
+		WithLintRules(LintRules...).
+		WithLargeTables("events", "audit.logs").
+		WithWideTables("users"))
+
+	tests := []struct {
+		sql  string
+		want []lintViolation
+	}{
+		// update and delete without where
+		{"UPDATE users SET name = 'a'", []lintViolation{{LintUpdateWithoutWhere, "users"}}},
+		{"UPDATE users SET name = 'a' WHERE id = 1", nil},
+		{"UPDATE users SET name = (SELECT name FROM companies WHERE id = 1)", []lintViolation{{LintUpdateWithoutWhere, "users"}}},
+		{"DELETE FROM `users`", []lintViolation{{LintDeleteWithoutWhere, "users"}}},
+		{"DELETE FROM users WHERE id IN (1, 2)", nil},
+		{"WITH old AS (SELECT id FROM users WHERE age > 99) DELETE FROM users", []lintViolation{{LintDeleteWithoutWhere, "users"}}},
+
+		// select without limit on large tables
+		{"SELECT * FROM events WHERE kind = 'a'", []lintViolation{{LintSelectWithoutLimit, "events"}}},
+		{"SELECT * FROM Events LIMIT 10", nil},
+		{"SELECT * FROM events FETCH FIRST 10 ROWS ONLY", nil},
+		{"SELECT count(*) FROM events", nil},
+		{"SELECT count(*) FROM events GROUP BY kind", []lintViolation{{LintSelectWithoutLimit, "events"}}},
+		{"SELECT id FROM audit.logs", []lintViolation{{LintSelectWithoutLimit, "audit.logs"}}},
+		{"SELECT id FROM other.logs", nil},
+		{"SELECT * FROM companies WHERE id IN (SELECT company_id FROM events LIMIT 1)", nil},
+
+		// select star on wide tables
+		{"SELECT * FROM users WHERE id = 1", []lintViolation{{LintSelectStar, "users"}}},
+		{"SELECT users.* FROM users JOIN companies ON companies.id = users.company_id", []lintViolation{{LintSelectStar, "users"}}},
+		{"SELECT id, name FROM users", nil},
+		{"SELECT count(*) FROM users", nil},
+		{"SELECT * FROM companies", nil},
+
+		// leading wildcard
+		{"SELECT id FROM companies WHERE name LIKE '%inc'", []lintViolation{{LintLeadingWildcard, ""}}},
+		{"SELECT id FROM companies WHERE name NOT ILIKE '_nc'", []lintViolation{{LintLeadingWildcard, ""}}},
+		{"SELECT id FROM companies WHERE name LIKE \"%inc\"", []lintViolation{{LintLeadingWildcard, ""}}},
+		{"SELECT id FROM companies WHERE name LIKE 'inc%'", nil},
+		{"SELECT id FROM companies WHERE name LIKE ?", nil},
+
+		// implicit cross join
+		{"SELECT c.id FROM companies c, teams t", []lintViolation{{LintImplicitCrossJoin, ""}}},
+		{"SELECT c.id FROM companies AS c, teams AS t WHERE t.company_id = c.id", nil},
+		{"SELECT c.id FROM companies c JOIN teams t", []lintViolation{{LintImplicitCrossJoin, ""}}},
+		{"SELECT c.id FROM companies c LEFT JOIN teams t ON t.company_id = c.id", nil},
+		{"SELECT c.id FROM companies c JOIN teams USING (company_id)", nil},
+		{"SELECT c.id FROM companies c CROSS JOIN teams t", nil},
+		{"SELECT c.id FROM companies c NATURAL JOIN teams", nil},
+		{"SELECT c.id FROM companies c JOIN (SELECT company_id FROM teams) t ON t.company_id = c.id", nil},
+		{"SELECT id FROM generate_series(1, 3) AS s, companies", []lintViolation{{LintImplicitCrossJoin, ""}}},
+		{"SELECT id FROM companies WHERE id IN (SELECT a.id FROM a, b)", nil},
+
+		// several rules
+		{"SELECT * FROM users, events WHERE name LIKE '%a'", []lintViolation{
+			{LintSelectWithoutLimit, "events"},
+			{LintSelectStar, "users"},
+			{LintLeadingWildcard, ""},
+		}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.want, l.lint(tt.sql))
+		})
+	}
+
+	t.Run("toggled rules", func(t *testing.T) {
+		l := NewWithConfig(NewConfig(New().slogHandler).WithLintRules(LintDeleteWithoutWhere).WithWideTables("users"))
+		assert.Nil(t, l.lint("UPDATE users SET name = 'a'"))
+		assert.Nil(t, l.lint("SELECT * FROM users"))
+		assert.Equal(t, []lintViolation{{LintDeleteWithoutWhere, "users"}}, l.lint("DELETE FROM users"))
+	})
+}
+
+func TestWithLintRules(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithLintRules(LintRules...).WithWideTables("test_users").WithTableKey("table"))
+	db := openTestDB(t, NewPlugin(l))
+	require.NoError(t, db.Create(&testUser{Name: "a", Company: testCompany{Name: "c"}}).Error)
+	buf.Reset()
+
+	var users []testUser
+	require.NoError(t, db.Where("name LIKE ?", "%a").Find(&users).Error)
+	require.NoError(t, db.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&testUser{}).Update("age", 1).Error)
+
+	records := buf.records(t)
+	require.Len(t, records, 3)
+	assert.Equal(t, map[string]any{
+		"time":  records[0]["time"],
+		"level": "WARN",
+		"msg":   "Query LINT",
+		"rule":  LintSelectStar,
+		"table": []any{"test_users"},
+		"file":  records[0]["file"],
+		"query": "SELECT * FROM `test_users` WHERE name LIKE \"%a\"",
+	}, records[0])
+	assert.Contains(t, records[0]["file"], "lint_test.go")
+	assert.Equal(t, LintLeadingWildcard, records[1]["rule"])
+	assert.NotContains(t, records[1], "table")
+	assert.Equal(t, LintUpdateWithoutWhere, records[2]["rule"])
+
+	t.Run("without table key", func(t *testing.T) {
+		l := NewWithConfig(NewConfig(buf.handler()).WithLintRules(LintRules...))
+		l.Trace(context.Background(), time.Now(), func() (string, int64) { return "DELETE FROM users", 1 }, nil)
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, LintDeleteWithoutWhere, records[0]["rule"])
+		assert.NotContains(t, records[0], "table")
+	})
+
+	t.Run("silent", func(t *testing.T) {
+		l := NewWithConfig(NewConfig(buf.handler()).WithLintRules(LintRules...).WithSilent(true))
+		l.Trace(context.Background(), time.Now(), func() (string, int64) { return "DELETE FROM users", 1 }, nil)
+		assert.Empty(t, buf.records(t))
+	})
+}
@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"log/slog"
 	"path"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -73,24 +77,95 @@ func (l *logger) Error(ctx context.Context, format string, args ...any) {
 
 // Trace logs sql message
 func (l *logger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	scope, budget := scopeFrom(ctx), budgetFrom(ctx)
+	if scope != nil || budget != nil || l.flightRecorder != nil || l.stats != nil || len(l.observers) > 0 || l.expvars != nil || len(l.lintRules) > 0 || l.checksRows() {
+		// account the statement even when silent, the SQL is only rendered once
+		sql, rows := fc()
+		fc = func() (string, int64) { return sql, rows }
+		file := utils.FileWithLineNum()
+		if l.stats != nil {
+			info := stmtInfoFrom(ctx)
+			if !l.stats.add(info.fingerprint(sql), info.sqlInfo(sql).operation, elapsed, rows, l.failed(err)) && l.expvars != nil {
+				l.expvars.statsDropped.Add(1)
+			}
+		}
+		if l.flightRecorder != nil {
+			l.record(ctx, elapsed, sql, rows, file, err)
+		}
+		if len(l.observers) > 0 || l.expvars != nil {
+			l.observe(ctx, begin, elapsed, sql, rows, file, err)
+		}
+		if scope != nil {
+			l.traceScope(ctx, scope, elapsed, sql, rows, file, err)
+		}
+		if len(l.lintRules) > 0 {
+			l.traceLint(ctx, sql, file)
+		}
+		if l.checksRows() {
+			l.traceRows(ctx, sql, rows, file, err)
+		}
+		if budget != nil {
+			l.traceBudget(ctx, budget, elapsed, sql, rows, file, err)
+		}
+	}
 	if l.silent {
 		return
 	}
 
-	elapsed := time.Since(begin)
+	failed := l.failed(err)
+	buffering := l.tailBufferSize > 0 && scope != nil
+	if failed && buffering {
+		// flush the OK-query records before the error
+		scope.fail(ctx, l)
+	}
+
 	switch {
-	case err != nil && l.enabled(ctx, slog.LevelError) && (!errors.Is(err, gorm.ErrRecordNotFound) || !l.ignoreRecordNotFoundError):
-		attrs := l.traceAttrs(ctx, elapsed, fc, utils.FileWithLineNum(), err, false)
-		l.log(ctx, slog.LevelError, l.errorMsg, attrs...)
+	case failed:
+		if level, msg := l.failure(ctx, err); l.enabled(ctx, level) {
+			attrs := l.traceAttrs(ctx, elapsed, fc, utils.FileWithLineNum(), err, false)
+			l.log(ctx, level, msg, attrs...)
+		}
 	case l.slowThreshold != 0 && elapsed > l.slowThreshold && l.enabled(ctx, slog.LevelWarn):
 		attrs := l.traceAttrs(ctx, elapsed, fc, utils.FileWithLineNum(), nil, true)
 		l.log(ctx, slog.LevelWarn, l.slowMsg, attrs...)
+		if info := stmtInfoFrom(ctx); info != nil && info.explainer != nil {
+			sql, _ := fc()
+			l.explain(ctx, info, elapsed, sql)
+		}
+	case buffering && err == nil && l.enabled(ctx, slog.LevelInfo):
+		attrs := l.traceAttrs(ctx, elapsed, fc, utils.FileWithLineNum(), nil, false)
+		if !l.buffer(ctx, scope, attrs) {
+			// the scope has failed already
+			l.log(ctx, slog.LevelInfo, l.okMsg, attrs...)
+		}
 	case l.traceAll && l.enabled(ctx, slog.LevelInfo):
 		attrs := l.traceAttrs(ctx, elapsed, fc, utils.FileWithLineNum(), nil, false)
 		l.log(ctx, slog.LevelInfo, l.okMsg, attrs...)
 	}
 }
 
+// failed reports whether the error of a traced statement is logged as a failure
+func (l *logger) failed(err error) bool {
+	return err != nil && (!errors.Is(err, gorm.ErrRecordNotFound) || !l.ignoreRecordNotFoundError)
+}
+
+// failure returns the level and message of a failed statement, the ones of a canceled or timed out statement if its error is a context one.
+// The context error, if any, tells which one, e.g. the driver may report a canceled context as a deadline exceeded.
+func (l *logger) failure(ctx context.Context, err error) (slog.Level, string) {
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return slog.LevelError, l.errorMsg
+	}
+	var ctxErr error
+	if ctx != nil {
+		ctxErr = ctx.Err()
+	}
+	if errors.Is(ctxErr, context.DeadlineExceeded) || (ctxErr == nil && errors.Is(err, context.DeadlineExceeded)) {
+		return l.timeoutLevel, l.timeoutMsg
+	}
+	return l.canceledLevel, l.canceledMsg
+}
+
 // ParamsFilter filter params
 func (l *logger) ParamsFilter(_ context.Context, sql string, params ...interface{}) (string, []interface{}) {
 	if l.parameterizedQueries {
@@ -116,7 +191,7 @@ func (l *logger) log(ctx context.Context, level slog.Level, msg string, attrs ..
 func (l *logger) traceAttrs(ctx context.Context, elapsed time.Duration, fc func() (string, int64), file string, err error, slow bool) []slog.Attr {
 	sql, rows := fc()
 
-	attrs := make([]slog.Attr, 0, 5)
+	attrs := make([]slog.Attr, 0, 12)
 
 	if l.durationKey != "" {
 		attrs = append(attrs, slog.Duration(l.durationKey, elapsed))
@@ -124,22 +199,60 @@ func (l *logger) traceAttrs(ctx context.Context, elapsed time.Duration, fc func(
 	if rows >= 0 && l.rowsKey != "" { // rows could be -1
 		attrs = append(attrs, slog.Int64(l.rowsKey, rows))
 	}
-	if l.sourceKey != "" {
-		if l.fullSourcePath {
-			attrs = append(attrs, slog.String(l.sourceKey, file))
-		} else {
-			attrs = append(attrs, slog.String(l.sourceKey, path.Base(file)))
-		}
-	}
+	attrs = l.appendSource(attrs, file)
 	if err != nil && l.errorKey != "" {
 		attrs = append(attrs, slog.Any(l.errorKey, err))
 	} else if slow && l.slowThresholdKey != "" {
 		attrs = append(attrs, slog.Duration(l.slowThresholdKey, l.slowThreshold))
 	}
+	if err != nil && l.causeKey != "" && ctx != nil {
+		// the cause given to context.WithCancelCause or context.WithTimeoutCause, e.g. why the request was aborted
+		if cause := context.Cause(ctx); cause != nil && cause != ctx.Err() {
+			attrs = append(attrs, slog.Any(l.causeKey, cause))
+		}
+	}
+	info := stmtInfoFrom(ctx)
+	if l.operationKey != "" || l.tableKey != "" {
+		parsed := info.sqlInfo(sql)
+		if l.operationKey != "" && parsed.operation != "" {
+			attrs = append(attrs, slog.String(l.operationKey, parsed.operation))
+		}
+		if l.tableKey != "" && len(parsed.tables) > 0 {
+			attrs = append(attrs, slog.Any(l.tableKey, parsed.tables))
+		}
+	}
+	if info != nil {
+		if l.modelKey != "" && info.model != "" {
+			attrs = append(attrs, slog.String(l.modelKey, info.model))
+		}
+		if l.clausesKey != "" && len(info.clauses) > 0 {
+			attrs = append(attrs, slog.Any(l.clausesKey, info.clauses))
+		}
+		if l.dialectKey != "" && info.dialect != "" {
+			attrs = append(attrs, slog.String(l.dialectKey, info.dialect))
+		}
+		if l.dryRunKey != "" && info.dryRun {
+			attrs = append(attrs, slog.Bool(l.dryRunKey, true))
+		}
+		if l.txIDKey != "" && info.tx != nil {
+			attrs = append(attrs, slog.String(l.txIDKey, info.tx.id))
+		}
+		if l.deadlineKey != "" && info.hasDeadline {
+			attrs = append(attrs, slog.Duration(l.deadlineKey, info.deadline))
+		}
+		if l.poolWaitKey != "" && info.poolWaited {
+			attrs = append(attrs, slog.Duration(l.poolWaitKey, info.poolWait))
+		}
+	}
 	if l.queryKey != "" {
 		attrs = append(attrs, slog.String(l.queryKey, sql))
 	}
 
+	return l.recordAttrs(ctx, attrs)
+}
+
+// recordAttrs prepends the context attributes to the given attributes, which are grouped by the group key if any
+func (l *logger) recordAttrs(ctx context.Context, attrs []slog.Attr) []slog.Attr {
 	if l.groupKey != "" {
 		return append(l.contextAttrs(ctx), slog.Attr{Key: l.groupKey, Value: slog.GroupValue(attrs...)})
 	}
@@ -147,6 +260,17 @@ func (l *logger) traceAttrs(ctx context.Context, elapsed time.Duration, fc func(
 	return append(l.contextAttrs(ctx), attrs...)
 }
 
+// appendSource appends the source attribute of the given file, if enabled
+func (l *logger) appendSource(attrs []slog.Attr, file string) []slog.Attr {
+	if l.sourceKey == "" {
+		return attrs
+	}
+	if l.fullSourcePath {
+		return append(attrs, slog.String(l.sourceKey, file))
+	}
+	return append(attrs, slog.String(l.sourceKey, path.Base(file)))
+}
+
 // contextAttrs extracts attributes from context
 func (l *logger) contextAttrs(ctx context.Context) []slog.Attr {
 	if ctx == nil {
@@ -169,3 +293,31 @@ func (l *logger) contextAttrs(ctx context.Context) []slog.Attr {
 func (l *logger) enabled(ctx context.Context, lvl slog.Level) bool {
 	return !l.silent && l.slogHandler.Enabled(ctx, lvl)
 }
+
+// gormSourceDir and packageSourceDir are the source directories skipped by fileWithLineNum
+var gormSourceDir, packageSourceDir string
+
+func init() {
+	_, file, _, _ := runtime.Caller(0)
+	packageSourceDir = filepath.Dir(file) + "/"
+	file, _ = runtime.FuncForPC(reflect.ValueOf(gorm.Open).Pointer()).FileLine(0)
+	gormSourceDir = filepath.Dir(file) + "/"
+}
+
+// fileWithLineNum is like utils.FileWithLineNum, but it also skips the frames of this package, except the tests.
+// It's meant for the records which are not logged by gorm's Trace calls, e.g. from the plugin callbacks.
+func fileWithLineNum() string {
+	pcs := [32]uintptr{}
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.File, gormSourceDir) || strings.HasPrefix(frame.File, packageSourceDir)
+		if (!internal || strings.HasSuffix(frame.File, "_test.go")) && !strings.HasSuffix(frame.File, ".gen.go") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
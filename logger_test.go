@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -44,6 +45,7 @@ func TestNewWithConfig(t *testing.T) {
 			parameterizedQueries:      true,
 			silent:                    true,
 			traceAll:                  true,
+			traceTransactions:         true,
 			contextKeys:               map[string]any{"req_id": "id"},
 			groupKey:                  "db",
 			errorKey:                  "err",
@@ -52,10 +54,54 @@ func TestNewWithConfig(t *testing.T) {
 			durationKey:               "dur",
 			rowsKey:                   "count",
 			sourceKey:                 "src",
+			operationKey:              "op",
+			tableKey:                  "tables",
+			modelKey:                  "type",
+			clausesKey:                "",
+			dialectKey:                "driver",
+			dryRunKey:                 "dry",
+			txIDKey:                   "tx",
+			poolWaitKey:               "wait",
+			causeKey:                  "why",
+			deadlineKey:               "",
 			fullSourcePath:            true,
+			nPlusOneThreshold:         3,
+			duplicateQueries:          false,
+			tailBufferSize:            100,
+			flightRecorder:            newFlightRecorder(5),
+			lintRules:                 map[string]bool{LintSelectStar: true},
+			largeTables:               map[string]bool{"events": true},
+			wideTables:                map[string]bool{"users": true, "audit.logs": true},
+			rowsThreshold:             1000,
+			operationRowsThresholds:   map[string]int64{OpDelete: 10},
+			tableRowsThresholds:       map[string]int64{"events": 0},
+			optimisticLockColumns:     map[string]bool{"version": true},
+			canceledLevel:             slog.LevelDebug,
+			timeoutLevel:              slog.LevelError,
 			okMsg:                     "Yeah!",
 			slowMsg:                   "Hmmm...",
 			errorMsg:                  "Shit!!",
+			canceledMsg:               "Gave up",
+			timeoutMsg:                "Too late",
+			txBeginMsg:                "Begin",
+			txCommitMsg:               "Commit",
+			txRollbackMsg:             "Rollback",
+			runningMsg:                "Running",
+			txRunningMsg:              "Still open",
+			nPlusOneMsg:               "N+1",
+			budgetExceededMsg:         "Over budget",
+			summaryMsg:                "Summary",
+			bufferDroppedMsg:          "Dropped",
+			flightDumpMsg:             "Dump",
+			recordedMsg:               "Recorded",
+			duplicateMsg:              "Duplicate",
+			reportMsg:                 "Report",
+			poolAlertMsg:              "Pool alert",
+			poolSummaryMsg:            "Pool summary",
+			explainMsg:                "Explain",
+			lintMsg:                   "Lint",
+			rowsExceededMsg:           "Too many rows",
+			staleUpdateMsg:            "Stale",
 		}
 
 		cfg := NewConfig(h).
@@ -64,6 +110,7 @@ func TestNewWithConfig(t *testing.T) {
 			WithParameterizedQueries(true).
 			WithSilent(true).
 			WithTraceAll(true).
+			WithTraceTransactions(true).
 			WithContextKeys(map[string]any{"req_id": "id"}).
 			WithGroupKey("db").
 			WithErrorKey("err").
@@ -72,10 +119,54 @@ func TestNewWithConfig(t *testing.T) {
 			WithDurationKey("dur").
 			WithRowsKey("count").
 			WithSourceKey("src").
+			WithOperationKey("op").
+			WithTableKey("tables").
+			WithModelKey("type").
+			WithClausesKey("").
+			WithDialectKey("driver").
+			WithDryRunKey("dry").
+			WithTxIDKey("tx").
+			WithPoolWaitKey("wait").
+			WithCauseKey("why").
+			WithDeadlineKey("").
 			WithFullSourcePath(true).
+			WithNPlusOneThreshold(3).
+			WithDuplicateQueries(false).
+			WithTailBuffer(100).
+			WithFlightRecorder(5).
+			WithLintRules(LintSelectStar).
+			WithLargeTables("Events").
+			WithWideTables("users", "audit.logs").
+			WithRowsThreshold(1000).
+			WithOperationRowsThresholds(map[string]int64{"delete": 10}).
+			WithTableRowsThresholds(map[string]int64{"Events": 0}).
+			WithOptimisticLockColumns("Version").
+			WithCanceledLevel(slog.LevelDebug).
+			WithTimeoutLevel(slog.LevelError).
 			WithOkMsg("Yeah!").
 			WithSlowMsg("Hmmm...").
-			WithErrorMsg("Shit!!")
+			WithErrorMsg("Shit!!").
+			WithCanceledMsg("Gave up").
+			WithTimeoutMsg("Too late").
+			WithTxBeginMsg("Begin").
+			WithTxCommitMsg("Commit").
+			WithTxRollbackMsg("Rollback").
+			WithRunningMsg("Running").
+			WithTxRunningMsg("Still open").
+			WithNPlusOneMsg("N+1").
+			WithBudgetExceededMsg("Over budget").
+			WithSummaryMsg("Summary").
+			WithBufferDroppedMsg("Dropped").
+			WithFlightDumpMsg("Dump").
+			WithRecordedMsg("Recorded").
+			WithDuplicateMsg("Duplicate").
+			WithReportMsg("Report").
+			WithPoolAlertMsg("Pool alert").
+			WithPoolSummaryMsg("Pool summary").
+			WithExplainMsg("Explain").
+			WithLintMsg("Lint").
+			WithRowsExceededMsg("Too many rows").
+			WithStaleUpdateMsg("Stale")
 		l := NewWithConfig(cfg)
 		assert.Equal(t, want, l.config)
 	})
@@ -362,6 +453,155 @@ func Test_logger(t *testing.T) {
 				hasAttr("query", "SELECT * FROM users"),
 			},
 		},
+		{
+			name: "trace operation and tables",
+			config: func(h slog.Handler) *config {
+				return NewConfig(h).WithTraceAll(true).WithOperationKey("operation").WithTableKey("table")
+			},
+			log: func(l *logger) {
+				fc := func() (string, int64) {
+					return "DELETE FROM users WHERE id IN (SELECT user_id FROM bans)", 2
+				}
+				l.Trace(context.Background(), time.Now(), fc, nil)
+			},
+			checks: []check{
+				hasAttr("operation", "DELETE"),
+				hasAttr("table", []any{"users", "bans"}),
+			},
+		},
+		{
+			name: "trace without operation and tables by default",
+			config: func(h slog.Handler) *config {
+				return NewConfig(h).WithTraceAll(true)
+			},
+			log: func(l *logger) {
+				fc := func() (string, int64) {
+					return "DELETE FROM users", 2
+				}
+				l.Trace(context.Background(), time.Now(), fc, nil)
+			},
+			checks: []check{
+				missingKey("operation"),
+				missingKey("table"),
+			},
+		},
+		{
+			name:   "trace canceled",
+			config: NewConfig,
+			log: func(l *logger) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT * FROM users", -1 }, context.Canceled)
+			},
+			checks: []check{
+				hasAttr(slog.LevelKey, "INFO"),
+				hasAttr(slog.MessageKey, "Query CANCELED"),
+				hasAttr("error", "context canceled"),
+				missingKey("cause"),
+				hasAttr("query", "SELECT * FROM users"),
+			},
+		},
+		{
+			name:   "trace canceled with cause",
+			config: NewConfig,
+			log: func(l *logger) {
+				ctx, cancel := context.WithCancelCause(context.Background())
+				cancel(errors.New("client disconnected"))
+				l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT * FROM users", -1 }, fmt.Errorf("interrupted: %w", context.Canceled))
+			},
+			checks: []check{
+				hasAttr(slog.LevelKey, "INFO"),
+				hasAttr(slog.MessageKey, "Query CANCELED"),
+				hasAttr("error", "interrupted: context canceled"),
+				hasAttr("cause", "client disconnected"),
+			},
+		},
+		{
+			name:   "trace error with a canceled context",
+			config: NewConfig,
+			log: func(l *logger) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				// a genuine failure, not caused by the context
+				l.Trace(ctx, time.Now(), func() (string, int64) { return "INSERT INTO users (name) VALUES (?)", 0 }, errors.New("UNIQUE constraint failed"))
+			},
+			checks: []check{
+				hasAttr(slog.LevelKey, "ERROR"),
+				hasAttr(slog.MessageKey, "Query ERROR"),
+				hasAttr("error", "UNIQUE constraint failed"),
+			},
+		},
+		{
+			name:   "trace canceled reported as deadline exceeded",
+			config: NewConfig,
+			log: func(l *logger) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT * FROM users", -1 }, context.DeadlineExceeded)
+			},
+			checks: []check{
+				hasAttr(slog.LevelKey, "INFO"),
+				hasAttr(slog.MessageKey, "Query CANCELED"),
+			},
+		},
+		{
+			name:   "trace timeout",
+			config: NewConfig,
+			log: func(l *logger) {
+				ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+				defer cancel()
+				l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT * FROM users", -1 }, context.DeadlineExceeded)
+			},
+			checks: []check{
+				hasAttr(slog.LevelKey, "WARN"),
+				hasAttr(slog.MessageKey, "Query TIMEOUT"),
+				hasAttr("error", "context deadline exceeded"),
+				missingKey("cause"),
+			},
+		},
+		{
+			name:   "trace timeout of the query only",
+			config: NewConfig,
+			log: func(l *logger) {
+				l.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT * FROM users", -1 }, fmt.Errorf("query: %w", context.DeadlineExceeded))
+			},
+			checks: []check{
+				hasAttr(slog.LevelKey, "WARN"),
+				hasAttr(slog.MessageKey, "Query TIMEOUT"),
+			},
+		},
+		{
+			name: "trace canceled with custom level and message",
+			config: func(h slog.Handler) *config {
+				return NewConfig(h).WithCanceledLevel(slog.LevelError).WithCanceledMsg("Gave up").WithCauseKey("")
+			},
+			log: func(l *logger) {
+				ctx, cancel := context.WithCancelCause(context.Background())
+				cancel(errors.New("client disconnected"))
+				l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT * FROM users", -1 }, context.Canceled)
+			},
+			checks: []check{
+				hasAttr(slog.LevelKey, "ERROR"),
+				hasAttr(slog.MessageKey, "Gave up"),
+				missingKey("cause"),
+			},
+		},
+		{
+			name:   "trace canceled below the level",
+			logLvl: slog.LevelWarn,
+			config: func(h slog.Handler) *config {
+				return NewConfig(h).WithSlowThreshold(time.Millisecond)
+			},
+			log: func(l *logger) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				// not logged as slow either
+				l.Trace(ctx, time.Now().Add(-time.Second), func() (string, int64) { return "SELECT * FROM users", -1 }, context.Canceled)
+			},
+			checks: []check{
+				emptyLogs(),
+			},
+		},
 		{
 			name: "full source path",
 			config: func(h slog.Handler) *config {
@@ -0,0 +1,130 @@
+package sloggorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Observer is notified of every traced statement, whatever its level, e.g. to export metrics, see WithObservers
+type Observer interface {
+	ObserveTrace(ctx context.Context, e TraceEvent)
+}
+
+// ObserverFunc is an Observer function
+type ObserverFunc func(ctx context.Context, e TraceEvent)
+
+// ObserveTrace calls f(ctx, e)
+func (f ObserverFunc) ObserveTrace(ctx context.Context, e TraceEvent) {
+	f(ctx, e)
+}
+
+// TraceEvent is the outcome of a traced statement
+type TraceEvent struct {
+	Begin       time.Time
+	Duration    time.Duration
+	Rows        int64 // -1 if unknown
+	Operation   string
+	Tables      []string // the primary table first
+	SQL         string   // as logged, see WithParameterizedQueries
+	Fingerprint string
+	Dialect     string // only set with the companion Plugin
+	Source      string
+	Err         error
+	Outcome     string // OutcomeOK, OutcomeSlow or OutcomeError
+}
+
+// Outcomes of the traced statements, see TraceEvent
+const (
+	OutcomeOK    = "ok"
+	OutcomeSlow  = "slow"
+	OutcomeError = "error"
+)
+
+// Table returns the primary table of the statement, if any
+func (e TraceEvent) Table() string {
+	if len(e.Tables) == 0 {
+		return ""
+	}
+	return e.Tables[0]
+}
+
+// Error classes, see ErrorClass
+const (
+	ErrorClassNone         = ""
+	ErrorClassNotFound     = "not_found"
+	ErrorClassCanceled     = "canceled"
+	ErrorClassDeadline     = "deadline_exceeded"
+	ErrorClassDuplicateKey = "duplicate_key"
+	ErrorClassForeignKey   = "foreign_key"
+	ErrorClassBadConn      = "bad_conn"
+	ErrorClassTx           = "transaction"
+	ErrorClassBudget       = "budget_exceeded"
+	ErrorClassOther        = "other"
+)
+
+// ErrorClass classifies an error into a small set of values, suitable for metric labels.
+//
+// The duplicate and foreign key errors are only recognized with gorm's TranslateError config.
+func ErrorClass(err error) string {
+	var budgetErr *BudgetExceededError
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrorClassNotFound
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassDeadline
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrorClassDuplicateKey
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return ErrorClassForeignKey
+	case errors.Is(err, driver.ErrBadConn):
+		return ErrorClassBadConn
+	case errors.Is(err, gorm.ErrInvalidTransaction):
+		return ErrorClassTx
+	case errors.As(err, &budgetErr):
+		return ErrorClassBudget
+	default:
+		return ErrorClassOther
+	}
+}
+
+// observe notifies the observers of a traced statement, and counts it in the published counters
+func (l *logger) observe(ctx context.Context, begin time.Time, elapsed time.Duration, sql string, rows int64, file string, err error) {
+	info := stmtInfoFrom(ctx)
+	parsed := info.sqlInfo(sql)
+	e := TraceEvent{
+		Begin:       begin,
+		Duration:    elapsed,
+		Rows:        rows,
+		Operation:   parsed.operation,
+		Tables:      parsed.tables,
+		SQL:         sql,
+		Fingerprint: info.fingerprint(sql),
+		Source:      file,
+		Err:         err,
+		Outcome:     OutcomeOK,
+	}
+	if info != nil {
+		e.Dialect = info.dialect
+	}
+	switch {
+	case l.failed(err):
+		e.Outcome = OutcomeError
+	case l.slowThreshold != 0 && elapsed > l.slowThreshold:
+		e.Outcome = OutcomeSlow
+	}
+
+	if l.expvars != nil {
+		l.expvars.add(e)
+	}
+	for _, o := range l.observers {
+		o.ObserveTrace(ctx, e)
+	}
+}
@@ -0,0 +1,107 @@
+package sloggorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// eventRecorder is an Observer collecting the events, it's safe for concurrent use
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []TraceEvent
+}
+
+func (r *eventRecorder) ObserveTrace(_ context.Context, e TraceEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) take() []TraceEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func TestWithObservers(t *testing.T) {
+	var buf logBuffer
+	var rec eventRecorder
+	var calls int
+	counter := ObserverFunc(func(context.Context, TraceEvent) { calls++ })
+	l := NewWithConfig(NewConfig(buf.handler()).WithObservers(&rec, counter).WithSlowThreshold(time.Hour))
+	db := openTestDB(t, NewPlugin(l))
+	rec.take()
+	calls = 0
+
+	require.NoError(t, db.Create(&testCompany{Name: "a"}).Error)
+	var companies []testCompany
+	require.NoError(t, db.Where("name = ?", "a").Find(&companies).Error)
+	assert.Error(t, db.Exec("SELECT * FROM missing").Error)
+
+	events := rec.take()
+	require.Len(t, events, 3)
+	assert.Equal(t, 3, calls)
+
+	insert := events[0]
+	assert.Equal(t, OpInsert, insert.Operation)
+	assert.Equal(t, "test_companies", insert.Table())
+	assert.Equal(t, "sqlite", insert.Dialect)
+	assert.Equal(t, int64(1), insert.Rows)
+	assert.Equal(t, OutcomeOK, insert.Outcome)
+	assert.Contains(t, insert.Source, "observer_test.go")
+	assert.False(t, insert.Begin.IsZero())
+
+	query := events[1]
+	assert.Equal(t, OpSelect, query.Operation)
+	assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = ?", query.Fingerprint)
+	assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = \"a\"", query.SQL)
+
+	failed := events[2]
+	assert.Equal(t, OutcomeError, failed.Outcome)
+	assert.Equal(t, "missing", failed.Table())
+	assert.Equal(t, ErrorClassOther, ErrorClass(failed.Err))
+
+	t.Run("silent and slow", func(t *testing.T) {
+		buf.Reset()
+		l := NewWithConfig(NewConfig(buf.handler()).WithObservers(&rec).WithSlowThreshold(time.Nanosecond).WithSilent(true))
+		l.Trace(context.Background(), time.Now().Add(-time.Second), func() (string, int64) { return "SELECT 1", -1 }, nil)
+		events := rec.take()
+		require.Len(t, events, 1)
+		assert.Equal(t, OutcomeSlow, events[0].Outcome)
+		assert.Equal(t, "", events[0].Table())
+		assert.Equal(t, "", events[0].Dialect)
+		assert.Empty(t, buf.records(t))
+	})
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ErrorClassNone},
+		{gorm.ErrRecordNotFound, ErrorClassNotFound},
+		{fmt.Errorf("query: %w", context.Canceled), ErrorClassCanceled},
+		{context.DeadlineExceeded, ErrorClassDeadline},
+		{gorm.ErrDuplicatedKey, ErrorClassDuplicateKey},
+		{gorm.ErrForeignKeyViolated, ErrorClassForeignKey},
+		{driver.ErrBadConn, ErrorClassBadConn},
+		{gorm.ErrInvalidTransaction, ErrorClassTx},
+		{&BudgetExceededError{}, ErrorClassBudget},
+		{errors.New("no such table"), ErrorClassOther},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ErrorClass(tt.err), tt.err)
+	}
+}
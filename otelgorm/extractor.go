@@ -0,0 +1,29 @@
+package otelgorm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+// NewContextExtractor creates a context extractor adding the trace and span IDs of the span in the context, see sloggorm.WithContextExtractor:
+//
+//	cfg.WithContextExtractor(otelgorm.NewContextExtractor().WithTraceFlagsKey("trace_flags").Extract)
+func NewContextExtractor() *sloggorm.TraceExtractor {
+	return sloggorm.NewTraceExtractor(SpanContextFrom)
+}
+
+// SpanContextFrom returns the trace context of the span in the context, if valid
+func SpanContextFrom(ctx context.Context) (sloggorm.TraceContext, bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return sloggorm.TraceContext{}, false
+	}
+	return sloggorm.TraceContext{
+		TraceID: sc.TraceID().String(),
+		SpanID:  sc.SpanID().String(),
+		Flags:   byte(sc.TraceFlags()),
+	}, true
+}
@@ -0,0 +1,29 @@
+package otelgorm
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewContextExtractor(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	assert.Equal(t, []slog.Attr{
+		slog.String("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"),
+		slog.String("span_id", "00f067aa0ba902b7"),
+		slog.String("trace_flags", "01"),
+	}, NewContextExtractor().WithTraceFlagsKey("trace_flags").Extract(ctx))
+
+	assert.Nil(t, NewContextExtractor().Extract(context.Background()))
+	assert.Nil(t, NewContextExtractor().Extract(trace.ContextWithSpanContext(context.Background(), trace.SpanContext{})))
+}
@@ -0,0 +1,111 @@
+// Package otelgorm exports the statements traced by sloggorm to OpenTelemetry, following the database semantic conventions.
+//
+//	metrics, err := otelgorm.NewMetrics(meterProvider)
+//	glogger := sloggorm.NewWithConfig(sloggorm.NewConfig(handler).WithObservers(metrics))
+package otelgorm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+// ScopeName is the instrumentation scope of the meters and tracers
+const ScopeName = "github.com/imdatngo/slog-gorm/v2/otelgorm"
+
+// Attribute keys of the database semantic conventions, and the outcome of the statements
+const (
+	DBSystemKey         = attribute.Key("db.system")
+	DBOperationNameKey  = attribute.Key("db.operation.name")
+	DBCollectionNameKey = attribute.Key("db.collection.name")
+	ErrorTypeKey        = attribute.Key("error.type")
+	OutcomeKey          = attribute.Key("gorm.outcome")
+)
+
+// durationBuckets are the boundaries advised by the semantic conventions, in seconds
+var durationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// dbSystems maps the gorm dialector names to the db.system values
+var dbSystems = map[string]string{
+	"sqlite":     "sqlite",
+	"mysql":      "mysql",
+	"postgres":   "postgresql",
+	"sqlserver":  "mssql",
+	"clickhouse": "clickhouse",
+}
+
+// NewMetrics creates the instruments of the statements traced by the loggers it observes, see sloggorm.WithObservers.
+// The global MeterProvider is used if mp is nil.
+//
+// The db.system attribute is only known with the companion sloggorm.Plugin, other_sql otherwise.
+func NewMetrics(mp metric.MeterProvider) (*Metrics, error) {
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(ScopeName)
+
+	duration, err := meter.Float64Histogram("db.client.operation.duration",
+		metric.WithDescription("Duration of database client operations."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := meter.Int64Histogram("db.client.response.returned_rows",
+		metric.WithDescription("The actual number of records returned by the database operation."),
+		metric.WithUnit("{row}"),
+		metric.WithExplicitBucketBoundaries(1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000))
+	if err != nil {
+		return nil, err
+	}
+	return &Metrics{duration: duration, rows: rows}, nil
+}
+
+// Metrics is a sloggorm.Observer recording the statements as OpenTelemetry metrics, see NewMetrics
+type Metrics struct {
+	duration metric.Float64Histogram
+	rows     metric.Int64Histogram
+	attrs    []attribute.KeyValue
+}
+
+// WithAttributes set the attributes added to all the measurements, e.g. db.namespace. Default none
+func (m *Metrics) WithAttributes(v ...attribute.KeyValue) *Metrics {
+	m.attrs = v
+	return m
+}
+
+// ObserveTrace implements sloggorm.Observer
+func (m *Metrics) ObserveTrace(ctx context.Context, e sloggorm.TraceEvent) {
+	attrs := append(eventAttrs(e), m.attrs...)
+	attrs = append(attrs, OutcomeKey.String(e.Outcome))
+	if e.Outcome == sloggorm.OutcomeError {
+		attrs = append(attrs, ErrorTypeKey.String(sloggorm.ErrorClass(e.Err)))
+	}
+	set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+
+	m.duration.Record(ctx, e.Duration.Seconds(), set)
+	if e.Operation == sloggorm.OpSelect && e.Rows >= 0 && e.Outcome != sloggorm.OutcomeError {
+		m.rows.Record(ctx, e.Rows, set)
+	}
+}
+
+// eventAttrs returns the semantic attributes of a statement
+func eventAttrs(e sloggorm.TraceEvent) []attribute.KeyValue {
+	system, ok := dbSystems[e.Dialect]
+	if !ok {
+		system = "other_sql"
+	}
+	attrs := make([]attribute.KeyValue, 0, 6)
+	attrs = append(attrs, DBSystemKey.String(system))
+	if e.Operation != "" {
+		attrs = append(attrs, DBOperationNameKey.String(e.Operation))
+	}
+	if table := e.Table(); table != "" {
+		attrs = append(attrs, DBCollectionNameKey.String(table))
+	}
+	return attrs
+}
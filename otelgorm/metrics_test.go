@@ -0,0 +1,125 @@
+package otelgorm
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"gorm.io/gorm"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+type testCompany struct {
+	ID   uint
+	Name string
+}
+
+// openTestDB opens a temporary SQLite database with the plugin installed, and a logger discarding the records
+func openTestDB(t *testing.T, observers ...sloggorm.Observer) *gorm.DB {
+	t.Helper()
+	l := sloggorm.NewWithConfig(sloggorm.NewConfig(slog.NewTextHandler(&strings.Builder{}, nil)).WithObservers(observers...))
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: l})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(sloggorm.NewPlugin(l)))
+	require.NoError(t, db.AutoMigrate(&testCompany{}))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// distinct returns the key of an attribute set
+func distinct(kvs ...attribute.KeyValue) attribute.Distinct {
+	set := attribute.NewSet(kvs...)
+	return set.Equivalent()
+}
+
+// histograms collects the data points of a histogram by their attributes
+func histograms[N int64 | float64](t *testing.T, reader sdkmetric.Reader, name string) map[attribute.Distinct]metricdata.HistogramDataPoint[N] {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	points := map[attribute.Distinct]metricdata.HistogramDataPoint[N]{}
+	for _, sm := range rm.ScopeMetrics {
+		assert.Equal(t, ScopeName, sm.Scope.Name)
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[N]).DataPoints {
+				points[distinct(dp.Attributes.ToSlice()...)] = dp
+			}
+		}
+	}
+	return points
+}
+
+func TestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	metrics, err := NewMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	require.NoError(t, err)
+	metrics.WithAttributes(attribute.String("db.namespace", "main"))
+
+	db := openTestDB(t, metrics)
+	for _, name := range []string{"a", "b"} {
+		require.NoError(t, db.Create(&testCompany{Name: name}).Error)
+	}
+	var companies []testCompany
+	require.NoError(t, db.Find(&companies).Error)
+	assert.Error(t, db.Exec("SELECT * FROM missing").Error)
+
+	durations := histograms[float64](t, reader, "db.client.operation.duration")
+	insert, ok := durations[distinct(
+		DBSystemKey.String("sqlite"),
+		DBOperationNameKey.String("INSERT"),
+		DBCollectionNameKey.String("test_companies"),
+		attribute.String("db.namespace", "main"),
+		OutcomeKey.String("ok"),
+	)]
+	require.True(t, ok, durations)
+	assert.Equal(t, uint64(2), insert.Count)
+	assert.Len(t, insert.Bounds, len(durationBuckets))
+
+	failed, ok := durations[distinct(
+		DBSystemKey.String("sqlite"),
+		DBOperationNameKey.String("SELECT"),
+		DBCollectionNameKey.String("missing"),
+		attribute.String("db.namespace", "main"),
+		OutcomeKey.String("error"),
+		ErrorTypeKey.String(sloggorm.ErrorClassOther),
+	)]
+	require.True(t, ok, durations)
+	assert.Equal(t, uint64(1), failed.Count)
+
+	rows := histograms[int64](t, reader, "db.client.response.returned_rows")
+	require.Len(t, rows, 1)
+	for _, dp := range rows {
+		assert.Equal(t, uint64(1), dp.Count)
+		assert.Equal(t, int64(2), dp.Sum)
+	}
+}
+
+func TestMetrics_withoutPlugin(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	metrics, err := NewMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	require.NoError(t, err)
+
+	metrics.ObserveTrace(context.Background(), sloggorm.TraceEvent{Rows: -1, Outcome: sloggorm.OutcomeSlow})
+	durations := histograms[float64](t, reader, "db.client.operation.duration")
+	_, ok := durations[distinct(DBSystemKey.String("other_sql"), OutcomeKey.String("slow"))]
+	assert.True(t, ok, durations)
+
+	_, err = NewMetrics(nil)
+	assert.NoError(t, err)
+}
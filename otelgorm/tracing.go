@@ -0,0 +1,107 @@
+package otelgorm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+// Attribute keys of the statements on the spans
+const (
+	DBQueryTextKey    = attribute.Key("db.query.text")
+	DBReturnedRowsKey = attribute.Key("db.response.returned_rows")
+	FingerprintKey    = attribute.Key("gorm.fingerprint")
+	SourceKey         = attribute.Key("code.filepath")
+)
+
+// NewTracing creates an observer adding the statements traced by the loggers to the span in their context, see sloggorm.WithObservers.
+// By default, a "db.query" event is added to the active span, which is marked as failed on errors.
+// The global TracerProvider is used if tp is nil, only for the child spans.
+//
+// The statements without a recording span in their context are ignored.
+func NewTracing(tp trace.TracerProvider) *Tracing {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracing{
+		tracer:    tp.Tracer(ScopeName),
+		queryText: true,
+	}
+}
+
+// Tracing is a sloggorm.Observer adding the statements to the spans, see NewTracing
+type Tracing struct {
+	tracer     trace.Tracer
+	childSpans bool
+	queryText  bool
+}
+
+// WithChildSpans set whether to create a child span per statement, from its begin and duration, instead of a span event. Default false
+func (t *Tracing) WithChildSpans(v bool) *Tracing {
+	t.childSpans = v
+	return t
+}
+
+// WithQueryText set whether to add the db.query.text attribute, the fingerprint is always added. Default true
+func (t *Tracing) WithQueryText(v bool) *Tracing {
+	t.queryText = v
+	return t
+}
+
+// ObserveTrace implements sloggorm.Observer
+func (t *Tracing) ObserveTrace(ctx context.Context, e sloggorm.TraceEvent) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := eventAttrs(e)
+	if t.queryText && e.SQL != "" {
+		attrs = append(attrs, DBQueryTextKey.String(e.SQL))
+	}
+	if e.Fingerprint != "" {
+		attrs = append(attrs, FingerprintKey.String(e.Fingerprint))
+	}
+	if e.Rows >= 0 {
+		attrs = append(attrs, DBReturnedRowsKey.Int64(e.Rows))
+	}
+	if e.Source != "" {
+		attrs = append(attrs, SourceKey.String(e.Source))
+	}
+	attrs = append(attrs, OutcomeKey.String(e.Outcome))
+	failed := e.Outcome == sloggorm.OutcomeError
+	if failed {
+		attrs = append(attrs, ErrorTypeKey.String(sloggorm.ErrorClass(e.Err)))
+	}
+
+	if t.childSpans {
+		_, span = t.tracer.Start(ctx, spanName(e),
+			trace.WithTimestamp(e.Begin),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...))
+		defer span.End(trace.WithTimestamp(e.Begin.Add(e.Duration)))
+	} else {
+		span.AddEvent("db.query", trace.WithTimestamp(e.Begin), trace.WithAttributes(attrs...))
+	}
+	if failed {
+		span.RecordError(e.Err, trace.WithTimestamp(e.Begin.Add(e.Duration)))
+		span.SetStatus(codes.Error, e.Err.Error())
+	}
+}
+
+// spanName returns the name of a child span, "{operation} {table}" per the semantic conventions
+func spanName(e sloggorm.TraceEvent) string {
+	switch {
+	case e.Operation == "":
+		return "db.query"
+	case e.Table() == "":
+		return e.Operation
+	default:
+		return e.Operation + " " + e.Table()
+	}
+}
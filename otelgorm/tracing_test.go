@@ -0,0 +1,106 @@
+package otelgorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+// attrsOf returns the attributes as a map
+func attrsOf(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	t.Run("span events", func(t *testing.T) {
+		db := openTestDB(t, NewTracing(tp))
+		require.NoError(t, db.Create(&testCompany{Name: "a"}).Error) // no span
+
+		ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+		var companies []testCompany
+		require.NoError(t, db.WithContext(ctx).Where("name = ?", "a").Find(&companies).Error)
+		assert.Error(t, db.WithContext(ctx).Exec("SELECT * FROM missing").Error)
+		span.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Contains(t, spans[0].Status().Description, "no such table")
+
+		events := spans[0].Events()
+		require.Len(t, events, 3) // the queries and the exception
+		assert.Equal(t, "db.query", events[0].Name)
+		attrs := attrsOf(events[0].Attributes)
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = \"a\"", attrs[DBQueryTextKey].AsString())
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = ?", attrs[FingerprintKey].AsString())
+		assert.Equal(t, int64(1), attrs[DBReturnedRowsKey].AsInt64())
+		assert.Equal(t, "sqlite", attrs[DBSystemKey].AsString())
+		assert.Equal(t, "SELECT", attrs[DBOperationNameKey].AsString())
+		assert.Equal(t, "test_companies", attrs[DBCollectionNameKey].AsString())
+		assert.Equal(t, "ok", attrs[OutcomeKey].AsString())
+		assert.Contains(t, attrs[SourceKey].AsString(), "tracing_test.go")
+
+		attrs = attrsOf(events[1].Attributes)
+		assert.Equal(t, "error", attrs[OutcomeKey].AsString())
+		assert.Equal(t, "other", attrs[ErrorTypeKey].AsString())
+		assert.Equal(t, "exception", events[2].Name)
+	})
+
+	t.Run("child spans", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		db := openTestDB(t, NewTracing(tp).WithChildSpans(true).WithQueryText(false))
+
+		ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+		require.NoError(t, db.WithContext(ctx).Create(&testCompany{Name: "a"}).Error)
+		assert.Error(t, db.WithContext(ctx).Exec("SELECT * FROM missing").Error)
+		span.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 3)
+		insert, failed, parent := spans[0], spans[1], spans[2]
+		assert.Equal(t, codes.Unset, parent.Status().Code)
+
+		assert.Equal(t, "INSERT test_companies", insert.Name())
+		assert.Equal(t, ScopeName, insert.InstrumentationScope().Name)
+		assert.Equal(t, trace.SpanKindClient, insert.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), insert.Parent().SpanID())
+		assert.False(t, insert.EndTime().Before(insert.StartTime()))
+		attrs := attrsOf(insert.Attributes())
+		assert.NotContains(t, attrs, DBQueryTextKey)
+		assert.Equal(t, "INSERT INTO `test_companies` (`name`) VALUES (?) RETURNING `id`", attrs[FingerprintKey].AsString())
+
+		assert.Equal(t, "SELECT missing", failed.Name())
+		assert.Equal(t, codes.Error, failed.Status().Code)
+	})
+}
+
+func Test_spanName(t *testing.T) {
+	tests := []struct {
+		event sloggorm.TraceEvent
+		want  string
+	}{
+		{sloggorm.TraceEvent{}, "db.query"},
+		{sloggorm.TraceEvent{Operation: sloggorm.OpExec}, "EXEC"},
+		{sloggorm.TraceEvent{Operation: sloggorm.OpUpdate, Tables: []string{"users", "companies"}}, "UPDATE users"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, spanName(tt.event))
+	}
+}
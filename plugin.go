@@ -0,0 +1,338 @@
+package sloggorm
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewPlugin creates a new gorm plugin with the given logger.
+//
+// The plugin captures the statement metadata which are not available to gormlogger.Interface, so Trace can log them.
+// It also installs the logger, so there is no need to set it in gorm.Config:
+//
+//	db.Use(sloggorm.NewPlugin(sloggorm.New()))
+func NewPlugin(l *logger) *Plugin {
+	return &Plugin{
+		logger:         l,
+		explainTimeout: 5 * time.Second,
+	}
+}
+
+// Plugin is the companion gorm.Plugin of the logger
+type Plugin struct {
+	logger    *logger
+	dialector gorm.Dialector
+
+	watchdogThreshold time.Duration
+	watchdog          *watchdog
+	inflightRegistry  bool
+	inflight          *inflight
+	strictBudget      bool
+	poolWait          bool
+	pool              DBStatser
+	explainInterval   time.Duration
+	explainTimeout    time.Duration
+	explainer         *explainer
+}
+
+// WithStrictBudget whether to fail the statements executed once the budget of their context is exceeded,
+// with a *BudgetExceededError. Default false. It's intended for tests and staging, see WithQueryBudget.
+func (p *Plugin) WithStrictBudget(v bool) *Plugin {
+	p.strictBudget = v
+	return p
+}
+
+// WithPoolWait whether to attach the time spent waiting for a connection to the records, as the pool_wait attribute, when the pool was exhausted. Default false.
+//
+// It's approximate: the pool statistics are sampled before and after each statement, so the concurrent waits are attributed to all the statements in progress.
+func (p *Plugin) WithPoolWait(v bool) *Plugin {
+	p.poolWait = v
+	return p
+}
+
+// WithAutoExplain enables the EXPLAIN of the slow SELECTs, at most once per fingerprint per interval. Default 0, i.e. disabled.
+//
+// The plan is logged as a follow-up record, once the EXPLAIN has run in the background on a separate connection.
+// It's supported by the sqlite, mysql, postgres and clickhouse dialects. Close waits for the pending ones.
+func (p *Plugin) WithAutoExplain(interval time.Duration) *Plugin {
+	p.explainInterval = interval
+	return p
+}
+
+// WithExplainTimeout set the timeout of the EXPLAIN of the slow SELECTs, see WithAutoExplain. Default 5s
+func (p *Plugin) WithExplainTimeout(timeout time.Duration) *Plugin {
+	p.explainTimeout = timeout
+	return p
+}
+
+// WithInflightRegistry whether to keep a registry of the statements and transactions in progress. Default false.
+//
+// See Inflight and InflightHandler to inspect it.
+func (p *Plugin) WithInflightRegistry(v bool) *Plugin {
+	p.inflightRegistry = v
+	return p
+}
+
+// WithWatchdog enables a watchdog warning about the statements and transactions still running after the given threshold,
+// then again each time their elapsed time doubles. Default 0, i.e. disabled.
+//
+// The watchdog goroutine is started with the plugin, and must be stopped with Close.
+func (p *Plugin) WithWatchdog(threshold time.Duration) *Plugin {
+	p.watchdogThreshold = threshold
+	return p
+}
+
+// Close stops the background goroutines of the plugin, if any
+func (p *Plugin) Close() error {
+	if p.watchdog != nil {
+		p.watchdog.close()
+	}
+	if p.explainer != nil {
+		p.explainer.wg.Wait()
+	}
+	return nil
+}
+
+// ensure our plugin implements gorm.Plugin
+var _ gorm.Plugin = (*Plugin)(nil)
+
+// Name returns the plugin name
+func (p *Plugin) Name() string {
+	return "sloggorm"
+}
+
+// Initialize installs the logger and registers the callbacks
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	db.Logger = p.logger
+	wrapConnPool(db, p)
+
+	p.dialector = db.Dialector
+	dialect := ""
+	if db.Dialector != nil {
+		dialect = db.Dialector.Name()
+	}
+
+	cb := db.Callback()
+	callbacks := []struct {
+		name      string
+		operation string
+		before    callbackRegisterer
+		after     callbackRegisterer
+	}{
+		{"create", OpInsert, cb.Create().Before("*"), cb.Create().After("*")},
+		{"query", OpSelect, cb.Query().Before("*"), cb.Query().After("*")},
+		{"update", OpUpdate, cb.Update().Before("*"), cb.Update().After("*")},
+		{"delete", OpDelete, cb.Delete().Before("*"), cb.Delete().After("*")},
+		{"row", OpSelect, cb.Row().Before("*"), cb.Row().After("*")},
+		{"raw", "", cb.Raw().Before("*"), cb.Raw().After("*")}, // the operation of raw SQL is parsed from the statement
+	}
+	for _, c := range callbacks {
+		if err := c.before.Register("sloggorm:before_"+c.name, p.before(c.operation, dialect)); err != nil {
+			return err
+		}
+		if err := c.after.Register("sloggorm:after_"+c.name, p.after); err != nil {
+			return err
+		}
+	}
+
+	if p.poolWait || p.explainInterval > 0 {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if p.poolWait {
+			p.pool = sqlDB
+		}
+		if p.explainInterval > 0 {
+			if p.explainer, err = newExplainer(sqlDB, dialect, p.explainInterval, p.explainTimeout); err != nil {
+				return err
+			}
+		}
+	}
+	if p.watchdogThreshold > 0 || p.inflightRegistry {
+		p.inflight = newInflight()
+	}
+	if p.watchdogThreshold > 0 {
+		p.startWatchdog()
+	}
+	return nil
+}
+
+// callbackRegisterer is implemented by the gorm callback builders
+type callbackRegisterer interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// before captures the statement metadata into the statement context
+func (p *Plugin) before(operation, dialect string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		if stmt.Context == nil {
+			stmt.Context = context.Background()
+		}
+
+		info, ok := stmt.Context.Value(stmtInfoKey{}).(*stmtInfo)
+		if ok && info.stmt == stmt {
+			// the statement is reused, e.g. chained without a new session, reset it instead of nesting contexts
+			*info = stmtInfo{stmt: stmt}
+		} else {
+			info = &stmtInfo{stmt: stmt}
+			stmt.Context = context.WithValue(stmt.Context, stmtInfoKey{}, info)
+		}
+
+		// the operation of raw SQL, e.g. db.Raw(...).Scan(...) running the row callbacks, is parsed from the statement
+		if stmt.SQL.Len() == 0 {
+			info.operation = operation
+		}
+		info.dialect = dialect
+		info.dryRun = db.DryRun
+		info.explainer = p.explainer
+		info.table = stmt.Table
+		if stmt.Schema != nil {
+			info.model = stmt.Schema.ModelType.String()
+		} else if stmt.Model != nil {
+			info.model = reflect.TypeOf(stmt.Model).String()
+		}
+
+		if deadline, ok := stmt.Context.Deadline(); ok {
+			info.deadline, info.hasDeadline = time.Until(deadline), true
+		}
+
+		if p.pool != nil {
+			stats := p.pool.Stats()
+			info.waitCount, info.waitDuration = stats.WaitCount, stats.WaitDuration
+		}
+
+		if p.strictBudget {
+			if err := budgetFrom(stmt.Context).err(); err != nil {
+				_ = db.AddError(err)
+			}
+		}
+
+		if p.inflight != nil {
+			e := &inflightEntry{
+				ctx:       stmt.Context,
+				start:     time.Now(),
+				source:    fileWithLineNum(),
+				operation: operation,
+				table:     stmt.Table,
+			}
+			if stmt.SQL.Len() > 0 {
+				// raw SQL is already built
+				e.setQuery(stmt.SQL.String(), stmt.Vars, nil)
+			}
+			p.inflight.add(e)
+			info.inflight = e
+		}
+	}
+}
+
+// after completes the statement metadata with what is only known once the statement has been built
+func (p *Plugin) after(db *gorm.DB) {
+	stmt := db.Statement
+	info := stmtInfoFrom(stmt.Context)
+	if info == nil || info.stmt != stmt {
+		return
+	}
+
+	if info.inflight != nil {
+		p.inflight.remove(info.inflight)
+		info.inflight = nil
+	}
+	if info.tx != nil {
+		// the end of a prepared statement, see txConn.StmtContext
+		info.tx.endPrepared(time.Now())
+	}
+
+	if p.pool != nil {
+		if stats := p.pool.Stats(); stats.WaitCount > info.waitCount {
+			info.poolWait = max(stats.WaitDuration-info.waitDuration, 0)
+			info.poolWaited = true
+		}
+	}
+
+	info.sql = stmt.SQL.String()
+	if scopeFrom(stmt.Context) != nil {
+		info.hash = statementHash(info.sql, stmt.Vars)
+	}
+	if info.table == "" {
+		info.table = stmt.Table
+	}
+	for _, name := range stmt.BuildClauses {
+		if _, ok := stmt.Clauses[name]; ok {
+			info.clauses = append(info.clauses, name)
+		}
+	}
+}
+
+// stmtInfo is the statement metadata captured by the Plugin, passed to Trace through the statement context
+type stmtInfo struct {
+	stmt      *gorm.Statement
+	operation string
+	table     string
+	model     string
+	clauses   []string
+	dryRun    bool
+	dialect   string
+	tx        *txInfo // the transaction the statement was executed in, if any
+	inflight  *inflightEntry
+	explainer *explainer
+	sql       string // the SQL with placeholders, as built by gorm
+	hash      uint64 // the hash of the SQL and params, only set within a query scope
+
+	// the time remaining before the context deadline when the statement started, if any
+	deadline    time.Duration
+	hasDeadline bool
+
+	// the pool statistics sampled before the statement, and the wait measured after it, see WithPoolWait
+	waitCount    int64
+	waitDuration time.Duration
+	poolWait     time.Duration
+	poolWaited   bool
+}
+
+type stmtInfoKey struct{}
+
+// sqlInfo merges the captured metadata with the ones parsed from the given SQL, the captured ones take precedence
+func (info *stmtInfo) sqlInfo(sql string) sqlInfo {
+	parsed := parseSQL(sql)
+	if info == nil {
+		return parsed
+	}
+
+	if info.operation != "" {
+		parsed.operation = info.operation
+	}
+	if info.table != "" {
+		tables := []string{info.table}
+		for _, t := range parsed.tables {
+			if t != info.table {
+				tables = append(tables, t)
+			}
+		}
+		parsed.tables = tables
+	}
+	return parsed
+}
+
+// fingerprint returns the fingerprint of the statement, from the SQL built by gorm if captured.
+//
+// The SQL passed to Trace has the params inlined, with strings quoted as identifiers by some dialectors, e.g. "value".
+func (info *stmtInfo) fingerprint(sql string) string {
+	if info != nil && info.sql != "" {
+		return fingerprint(info.sql)
+	}
+	return fingerprint(sql)
+}
+
+// stmtInfoFrom returns the statement metadata from context, or nil if the plugin is not installed
+func stmtInfoFrom(ctx context.Context) *stmtInfo {
+	if ctx == nil {
+		return nil
+	}
+	info, _ := ctx.Value(stmtInfoKey{}).(*stmtInfo)
+	return info
+}
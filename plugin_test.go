@@ -0,0 +1,240 @@
+package sloggorm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type testCompany struct {
+	ID   uint
+	Name string
+}
+
+type testUser struct {
+	ID        uint
+	Name      string
+	Age       int
+	CompanyID uint
+	Company   testCompany
+}
+
+// logBuffer collects JSON log records, it's safe for concurrent use
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func (b *logBuffer) handler() slog.Handler {
+	return slog.NewJSONHandler(b, &slog.HandlerOptions{Level: slog.LevelDebug})
+}
+
+// records decodes all the log records and resets the buffer
+func (b *logBuffer) records(t *testing.T) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		m := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m), line)
+		records = append(records, m)
+	}
+	b.buf.Reset()
+	return records
+}
+
+// openTestDB opens a temporary SQLite database with the plugin installed
+func openTestDB(t *testing.T, p *Plugin) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(p))
+	require.NoError(t, db.Session(&gorm.Session{Logger: db.Logger.LogMode(gormlogger.Silent)}).AutoMigrate(&testCompany{}, &testUser{}))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestPlugin(t *testing.T) {
+	var buf logBuffer
+	cfg := NewConfig(buf.handler()).WithTraceAll(true).WithOperationKey("operation").WithTableKey("table")
+	l := NewWithConfig(cfg)
+	p := NewPlugin(l)
+	db := openTestDB(t, p)
+	buf.Reset()
+
+	t.Run("installs the logger", func(t *testing.T) {
+		assert.Same(t, l, db.Logger)
+		assert.Equal(t, "sloggorm", p.Name())
+		assert.ErrorIs(t, db.Use(NewPlugin(l)), gorm.ErrRegistered)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		require.NoError(t, db.Create(&testUser{Name: "jinzhu", Age: 18, Company: testCompany{Name: "gorm"}}).Error)
+		records := buf.records(t)
+		require.Len(t, records, 2)
+		// the association is saved first
+		assert.Equal(t, "INSERT", records[0]["operation"])
+		assert.Equal(t, []any{"test_companies"}, records[0]["table"])
+		assert.Equal(t, "sloggorm.testCompany", records[0]["model"])
+		assert.Equal(t, "INSERT", records[1]["operation"])
+		assert.Equal(t, []any{"test_users"}, records[1]["table"])
+		assert.Equal(t, "sloggorm.testUser", records[1]["model"])
+		assert.Equal(t, "sqlite", records[1]["dialect"])
+		assert.Contains(t, records[1]["clauses"], "INSERT")
+		assert.NotContains(t, records[1], "dry_run")
+	})
+
+	t.Run("query with join", func(t *testing.T) {
+		var users []testUser
+		require.NoError(t, db.Joins("Company").Where("age > ?", 10).Find(&users).Error)
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "SELECT", records[0]["operation"])
+		assert.Equal(t, []any{"test_users", "test_companies"}, records[0]["table"])
+		assert.Equal(t, []any{"SELECT", "FROM", "WHERE"}, records[0]["clauses"])
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		require.NoError(t, db.Exec("DELETE FROM test_users WHERE age < ?", 0).Error)
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "DELETE", records[0]["operation"])
+		assert.Equal(t, []any{"test_users"}, records[0]["table"])
+		assert.NotContains(t, records[0], "model")
+	})
+
+	t.Run("raw scanned", func(t *testing.T) {
+		// the row and query callbacks run the raw SQL as is
+		var ids []uint
+		require.NoError(t, db.Raw("UPDATE test_users SET age = ? RETURNING id", 1).Scan(&ids).Error)
+		var ages []int
+		require.NoError(t, db.Raw("SELECT age FROM test_users").Find(&ages).Error)
+		records := buf.records(t)
+		require.Len(t, records, 2)
+		assert.Equal(t, "UPDATE", records[0]["operation"])
+		assert.Equal(t, []any{"test_users"}, records[0]["table"])
+		assert.Equal(t, "SELECT", records[1]["operation"])
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		db.Session(&gorm.Session{DryRun: true}).Model(&testUser{ID: 1}).Update("age", 20)
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "UPDATE", records[0]["operation"])
+		assert.Equal(t, true, records[0]["dry_run"])
+	})
+
+	t.Run("reused statement", func(t *testing.T) {
+		tx := db.Model(&testUser{}).Where("age > ?", 0)
+		var count int64
+		tx.Count(&count)
+		ctx := tx.Statement.Context
+		require.NotNil(t, stmtInfoFrom(ctx))
+		tx.Count(&count)
+		// the context is not nested, despite being executed twice
+		assert.Equal(t, ctx, tx.Statement.Context)
+		assert.Len(t, buf.records(t), 2)
+	})
+}
+
+func TestPlugin_WithPoolWait(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithSlowThreshold(20 * time.Millisecond))
+	db := openTestDB(t, NewPlugin(l).WithPoolWait(true))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.Create(&testCompany{Name: "a"}).Error)
+	buf.Reset()
+
+	t.Run("not waiting", func(t *testing.T) {
+		var companies []testCompany
+		require.NoError(t, db.Debug().Find(&companies).Error)
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.NotContains(t, records[0], "pool_wait")
+	})
+
+	t.Run("starved", func(t *testing.T) {
+		tx := db.Session(&gorm.Session{Logger: db.Logger.LogMode(gormlogger.Silent)}).Begin()
+		require.NoError(t, tx.Error)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var companies []testCompany
+			assert.NoError(t, db.Find(&companies).Error)
+		}()
+		time.Sleep(50 * time.Millisecond) // the query waits for the connection held by the transaction
+		require.NoError(t, tx.Commit().Error)
+		<-done
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "Query SLOW", records[0]["msg"])
+		assert.GreaterOrEqual(t, records[0]["pool_wait"], float64(40*time.Millisecond))
+		assert.LessOrEqual(t, records[0]["pool_wait"], records[0]["duration"])
+	})
+}
+
+func TestPlugin_deadline(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithTraceAll(true))
+	db := openTestDB(t, NewPlugin(l))
+	buf.Reset()
+
+	var companies []testCompany
+	require.NoError(t, db.Find(&companies).Error)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	require.NoError(t, db.WithContext(ctx).Find(&companies).Error)
+
+	records := buf.records(t)
+	require.Len(t, records, 2)
+	assert.NotContains(t, records[0], "deadline_remaining")
+	assert.Greater(t, records[1]["deadline_remaining"], float64(50*time.Second))
+	assert.LessOrEqual(t, records[1]["deadline_remaining"], float64(time.Minute))
+
+	t.Run("caller gave up", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		cancel()
+		require.Error(t, db.WithContext(ctx).Find(&companies).Error)
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "INFO", records[0]["level"])
+		assert.Equal(t, "Query CANCELED", records[0]["msg"])
+		assert.Contains(t, records[0], "deadline_remaining")
+	})
+}
@@ -0,0 +1,186 @@
+package sloggorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DBStatser provides the connection pool statistics, e.g. *sql.DB
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+// PoolThresholds are the conditions alerting on a connection pool, within an interval of the monitor. The zero values disable them.
+type PoolThresholds struct {
+	WaitCount    int64         // the connections waited for
+	WaitDuration time.Duration // the total time blocked waiting for a connection
+	OpenRatio    float64       // the open connections over MaxOpenConns, only with a limit
+	Churn        int64         // the connections closed due to SetMaxIdleConns, SetConnMaxIdleTime or SetConnMaxLifetime
+}
+
+// DefaultPoolThresholds are the thresholds of the monitors, see NewPoolMonitor
+var DefaultPoolThresholds = PoolThresholds{
+	WaitCount:    1,
+	WaitDuration: 100 * time.Millisecond,
+	OpenRatio:    0.9,
+	Churn:        10,
+}
+
+// NewPoolMonitor creates a monitor reading the statistics of the connection pools every interval,
+// and logging a warning when a threshold is crossed, see PoolThresholds:
+//
+//	sqlDB, err := db.DB()
+//	monitor := sloggorm.NewPoolMonitor(glogger, 10*time.Second).WithDB("main", sqlDB)
+//	err = monitor.Start()
+//	defer monitor.Close()
+func NewPoolMonitor(l *logger, interval time.Duration) *PoolMonitor {
+	return &PoolMonitor{
+		logger:     l,
+		interval:   interval,
+		thresholds: DefaultPoolThresholds,
+		clock:      systemClock{},
+	}
+}
+
+// PoolMonitor periodically checks the connection pools, see NewPoolMonitor
+type PoolMonitor struct {
+	logger     *logger
+	interval   time.Duration
+	thresholds PoolThresholds
+	summary    bool
+	clock      Clock
+	pools      []*monitoredPool
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// monitoredPool is a named connection pool with its statistics at the previous check
+type monitoredPool struct {
+	name string
+	db   DBStatser
+	last sql.DBStats
+}
+
+// WithDB adds a named connection pool to monitor, e.g. "primary" and "replica"
+func (m *PoolMonitor) WithDB(name string, db DBStatser) *PoolMonitor {
+	m.pools = append(m.pools, &monitoredPool{name: name, db: db})
+	return m
+}
+
+// WithThresholds set the conditions alerting on the pools. Default DefaultPoolThresholds
+func (m *PoolMonitor) WithThresholds(v PoolThresholds) *PoolMonitor {
+	m.thresholds = v
+	return m
+}
+
+// WithSummary set whether to log the statistics of every pool at every interval, whatever the thresholds. Default false
+func (m *PoolMonitor) WithSummary(v bool) *PoolMonitor {
+	m.summary = v
+	return m
+}
+
+// WithClock set the clock driving the checks. Default is the system clock
+func (m *PoolMonitor) WithClock(c Clock) *PoolMonitor {
+	m.clock = c
+	return m
+}
+
+// Start starts the monitor goroutine, which must be stopped with Close
+func (m *PoolMonitor) Start() error {
+	if len(m.pools) == 0 {
+		return errors.New("sloggorm: no connection pool to monitor, see WithDB")
+	}
+	if m.interval <= 0 {
+		return errors.New("sloggorm: the monitor interval must be positive")
+	}
+
+	m.startOnce.Do(func() {
+		for _, p := range m.pools {
+			p.last = p.db.Stats()
+		}
+		m.stop = make(chan struct{})
+		m.done = make(chan struct{})
+		ticks, stopTicker := m.clock.NewTicker(m.interval)
+		go func() {
+			defer close(m.done)
+			defer stopTicker()
+			for {
+				select {
+				case <-m.stop:
+					return
+				case <-ticks:
+					m.check()
+				}
+			}
+		}()
+	})
+	return nil
+}
+
+// Close stops the monitor goroutine and waits for it to return
+func (m *PoolMonitor) Close() error {
+	if m.stop == nil {
+		return nil
+	}
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+	return nil
+}
+
+// check logs the pools crossing a threshold since the previous check, and the summaries if enabled
+func (m *PoolMonitor) check() {
+	l := m.logger
+	ctx := context.Background()
+	for _, p := range m.pools {
+		current := p.db.Stats()
+		last := p.last
+		p.last = current
+
+		waitCount := current.WaitCount - last.WaitCount
+		waitDuration := current.WaitDuration - last.WaitDuration
+		churn := current.MaxIdleClosed + current.MaxIdleTimeClosed + current.MaxLifetimeClosed -
+			last.MaxIdleClosed - last.MaxIdleTimeClosed - last.MaxLifetimeClosed
+
+		var reasons []string
+		t := m.thresholds
+		if t.WaitCount > 0 && waitCount >= t.WaitCount {
+			reasons = append(reasons, "wait_count")
+		}
+		if t.WaitDuration > 0 && waitDuration >= t.WaitDuration {
+			reasons = append(reasons, "wait_duration")
+		}
+		if t.OpenRatio > 0 && current.MaxOpenConnections > 0 &&
+			float64(current.OpenConnections) >= t.OpenRatio*float64(current.MaxOpenConnections) {
+			reasons = append(reasons, "open_connections")
+		}
+		if t.Churn > 0 && churn >= t.Churn {
+			reasons = append(reasons, "churn")
+		}
+
+		attrs := []slog.Attr{
+			slog.String("db", p.name),
+			slog.Int("open", current.OpenConnections),
+			slog.Int("in_use", current.InUse),
+			slog.Int("idle", current.Idle),
+			slog.Int("max_open", current.MaxOpenConnections),
+			slog.Int64("wait_count", waitCount),
+			slog.Duration("wait_duration", waitDuration),
+			slog.Int64("closed", churn),
+		}
+		if len(reasons) > 0 && l.enabled(ctx, slog.LevelWarn) {
+			l.log(ctx, slog.LevelWarn, l.poolAlertMsg, l.recordAttrs(ctx, append(attrs, slog.Any("reasons", reasons)))...)
+		}
+		if m.summary && l.enabled(ctx, slog.LevelInfo) {
+			l.log(ctx, slog.LevelInfo, l.poolSummaryMsg, l.recordAttrs(ctx, attrs)...)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package sloggorm
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePool is a DBStatser driven by the tests
+type fakePool struct {
+	mu    sync.Mutex
+	stats sql.DBStats
+}
+
+func (p *fakePool) Stats() sql.DBStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+func (p *fakePool) update(fn func(s *sql.DBStats)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(&p.stats)
+}
+
+func TestPoolMonitor(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()))
+	primary := &fakePool{stats: sql.DBStats{MaxOpenConnections: 10, OpenConnections: 2, WaitCount: 5, WaitDuration: time.Second}}
+	replica := &fakePool{}
+
+	clock := newFakeClock()
+	m := NewPoolMonitor(l, time.Minute).WithDB("primary", primary).WithDB("replica", replica).WithClock(clock)
+	require.NoError(t, m.Start())
+	require.NoError(t, m.Start())
+
+	// the checks are run directly, without ticks, to be deterministic
+	t.Run("healthy", func(t *testing.T) {
+		m.check()
+		assert.Empty(t, buf.records(t))
+	})
+
+	t.Run("thresholds", func(t *testing.T) {
+		primary.update(func(s *sql.DBStats) {
+			s.OpenConnections, s.InUse, s.Idle = 9, 8, 1
+			s.WaitCount += 3
+			s.WaitDuration += 50 * time.Millisecond
+		})
+		replica.update(func(s *sql.DBStats) {
+			s.OpenConnections = 3
+			s.WaitDuration += time.Second
+			s.MaxIdleClosed, s.MaxLifetimeClosed = 6, 4
+		})
+		m.check()
+
+		records := buf.records(t)
+		require.Len(t, records, 2)
+		assert.Equal(t, map[string]any{
+			"time":          records[0]["time"],
+			"level":         "WARN",
+			"msg":           "Pool ALERT",
+			"db":            "primary",
+			"open":          float64(9),
+			"in_use":        float64(8),
+			"idle":          float64(1),
+			"max_open":      float64(10),
+			"wait_count":    float64(3),
+			"wait_duration": float64(50 * time.Millisecond),
+			"closed":        float64(0),
+			"reasons":       []any{"wait_count", "open_connections"},
+		}, records[0])
+		assert.Equal(t, "replica", records[1]["db"])
+		assert.Equal(t, []any{"wait_duration", "churn"}, records[1]["reasons"])
+		assert.Equal(t, float64(10), records[1]["closed"])
+	})
+
+	t.Run("ticks", func(t *testing.T) {
+		clock.tick(time.Minute)
+		require.NoError(t, m.Close()) // waits for the check
+		require.NoError(t, m.Close())
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "primary", records[0]["db"])
+		assert.Equal(t, []any{"open_connections"}, records[0]["reasons"]) // still near the limit, without new waits
+	})
+	clock.mu.Lock()
+	assert.True(t, clock.stopped)
+	clock.mu.Unlock()
+
+	t.Run("summary", func(t *testing.T) {
+		clock := newFakeClock()
+		m := NewPoolMonitor(l, time.Minute).WithDB("primary", primary).WithClock(clock).
+			WithSummary(true).WithThresholds(PoolThresholds{})
+		require.NoError(t, m.Start())
+		primary.update(func(s *sql.DBStats) { s.WaitCount += 100 })
+		clock.tick(time.Minute)
+		require.NoError(t, m.Close())
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "INFO", records[0]["level"])
+		assert.Equal(t, "Pool SUMMARY", records[0]["msg"])
+		assert.Equal(t, float64(100), records[0]["wait_count"])
+		assert.NotContains(t, records[0], "reasons")
+	})
+}
+
+func TestPoolMonitor_Start(t *testing.T) {
+	assert.Error(t, NewPoolMonitor(New(), time.Minute).Start())
+	assert.Error(t, NewPoolMonitor(New(), 0).WithDB("main", &fakePool{}).Start())
+	assert.NoError(t, NewPoolMonitor(New(), time.Minute).Close())
+
+	// a real pool and the system clock
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()))
+	db := openTestDB(t, NewPlugin(l))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	m := NewPoolMonitor(l, time.Millisecond).WithDB("main", sqlDB).WithSummary(true)
+	require.NoError(t, m.Start())
+	var records []map[string]any
+	assert.Eventually(t, func() bool {
+		records = append(records, buf.records(t)...)
+		return len(records) > 0
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, m.Close())
+	require.NotEmpty(t, records)
+	assert.Equal(t, "main", records[0]["db"])
+}
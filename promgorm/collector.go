@@ -0,0 +1,166 @@
+// Package promgorm exports the statements traced by sloggorm as Prometheus metrics.
+//
+//	collector := promgorm.NewCollector().WithNamespace("app")
+//	prometheus.MustRegister(collector)
+//	glogger := sloggorm.NewWithConfig(sloggorm.NewConfig(handler).WithObservers(collector))
+package promgorm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+// OtherLabel replaces the label values beyond the cardinality limits
+const OtherLabel = "other"
+
+// operations are the operation label values, any other operation is reported as OtherLabel
+var operations = map[string]bool{
+	sloggorm.OpSelect: true,
+	sloggorm.OpInsert: true,
+	sloggorm.OpUpdate: true,
+	sloggorm.OpDelete: true,
+	sloggorm.OpDDL:    true,
+	sloggorm.OpExec:   true,
+	sloggorm.OpRaw:    true,
+}
+
+// NewCollector creates a collector of the statements traced by the loggers it observes, see sloggorm.WithObservers.
+// The builders must be called before the collector is registered or used.
+func NewCollector() *Collector {
+	return &Collector{
+		namespace: "gorm",
+		buckets:   prometheus.DefBuckets,
+		maxTables: 100,
+	}
+}
+
+// Collector is a prometheus.Collector and a sloggorm.Observer, see NewCollector
+type Collector struct {
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+	maxTables   int
+
+	initOnce sync.Once
+	duration *prometheus.HistogramVec
+	queries  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	rows     *prometheus.CounterVec
+
+	mu     sync.Mutex
+	tables map[string]bool
+}
+
+// WithNamespace set the namespace of the metric names. Default "gorm"
+func (c *Collector) WithNamespace(v string) *Collector {
+	c.namespace = v
+	return c
+}
+
+// WithConstLabels set the labels added to all the metrics, e.g. the database name. Default none
+func (c *Collector) WithConstLabels(v prometheus.Labels) *Collector {
+	c.constLabels = v
+	return c
+}
+
+// WithBuckets set the buckets of the duration histogram, in seconds. Default prometheus.DefBuckets
+func (c *Collector) WithBuckets(v []float64) *Collector {
+	c.buckets = v
+	return c
+}
+
+// WithMaxTables set the number of distinct table label values, the next tables are reported as OtherLabel. Default 100
+func (c *Collector) WithMaxTables(v int) *Collector {
+	c.maxTables = v
+	return c
+}
+
+// init creates the metrics from the builders
+func (c *Collector) init() {
+	c.initOnce.Do(func() {
+		c.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Name:        "query_duration_seconds",
+			Help:        "Duration of the SQL statements.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.buckets,
+		}, []string{"operation", "table", "outcome"})
+		c.queries = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Name:        "queries_total",
+			Help:        "Number of SQL statements, by outcome: ok, slow or error.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation", "table", "outcome"})
+		c.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Name:        "query_errors_total",
+			Help:        "Number of failed SQL statements, by error class.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation", "table", "error_class"})
+		c.rows = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Name:        "query_rows_total",
+			Help:        "Number of rows returned or affected by the SQL statements.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation", "table"})
+		c.tables = map[string]bool{}
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.init()
+	c.duration.Describe(ch)
+	c.queries.Describe(ch)
+	c.errors.Describe(ch)
+	c.rows.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.init()
+	c.duration.Collect(ch)
+	c.queries.Collect(ch)
+	c.errors.Collect(ch)
+	c.rows.Collect(ch)
+}
+
+// ObserveTrace implements sloggorm.Observer
+func (c *Collector) ObserveTrace(_ context.Context, e sloggorm.TraceEvent) {
+	c.init()
+	op := e.Operation
+	if !operations[op] {
+		op = OtherLabel
+	}
+	table := c.table(e.Table())
+
+	c.duration.WithLabelValues(op, table, e.Outcome).Observe(e.Duration.Seconds())
+	c.queries.WithLabelValues(op, table, e.Outcome).Inc()
+	if e.Outcome == sloggorm.OutcomeError {
+		c.errors.WithLabelValues(op, table, sloggorm.ErrorClass(e.Err)).Inc()
+	}
+	if e.Rows > 0 {
+		c.rows.WithLabelValues(op, table).Add(float64(e.Rows))
+	}
+}
+
+// table returns the table label value, OtherLabel once the limit of distinct tables is reached
+func (c *Collector) table(name string) string {
+	if name == "" {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tables[name] {
+		return name
+	}
+	if len(c.tables) >= c.maxTables {
+		return OtherLabel
+	}
+	c.tables[name] = true
+	return name
+}
@@ -0,0 +1,96 @@
+package promgorm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+type testCompany struct {
+	ID   uint
+	Name string
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector().WithNamespace("app").WithBuckets([]float64{0.1, 1}).WithConstLabels(prometheus.Labels{"db": "main"})
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+
+	l := sloggorm.NewWithConfig(sloggorm.NewConfig(slog.New(slog.NewTextHandler(&strings.Builder{}, nil)).Handler()).WithObservers(c))
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: l})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(sloggorm.NewPlugin(l)))
+	require.NoError(t, db.AutoMigrate(&testCompany{}))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	for _, name := range []string{"a", "b"} {
+		require.NoError(t, db.Create(&testCompany{Name: name}).Error)
+	}
+	var companies []testCompany
+	require.NoError(t, db.Find(&companies).Error)
+	assert.Error(t, db.Exec("SELECT * FROM missing").Error)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.queries.WithLabelValues("INSERT", "test_companies", "ok")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.queries.WithLabelValues("SELECT", "test_companies", "ok")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.queries.WithLabelValues("SELECT", "missing", "error")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.errors.WithLabelValues("SELECT", "missing", "other")))
+	assert.Equal(t, float64(4), testutil.ToFloat64(c.rows.WithLabelValues("INSERT", "test_companies"))+testutil.ToFloat64(c.rows.WithLabelValues("SELECT", "test_companies")))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, f := range families {
+		names[f.GetName()] = true
+		for _, m := range f.GetMetric() {
+			assert.Equal(t, "db", m.GetLabel()[0].GetName())
+			assert.Equal(t, "main", m.GetLabel()[0].GetValue())
+			if h := m.GetHistogram(); h != nil {
+				assert.Len(t, h.GetBucket(), 2)
+			}
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		"app_query_duration_seconds": true,
+		"app_queries_total":          true,
+		"app_query_errors_total":     true,
+		"app_query_rows_total":       true,
+	}, names)
+}
+
+func TestCollector_ObserveTrace(t *testing.T) {
+	ctx := context.Background()
+	c := NewCollector().WithMaxTables(2)
+
+	for _, table := range []string{"a", "b", "c", "d", "a"} {
+		c.ObserveTrace(ctx, sloggorm.TraceEvent{Operation: sloggorm.OpSelect, Tables: []string{table}, Outcome: sloggorm.OutcomeOK})
+	}
+	c.ObserveTrace(ctx, sloggorm.TraceEvent{Operation: "VACUUM", Duration: 2 * time.Second, Outcome: sloggorm.OutcomeSlow})
+	c.ObserveTrace(ctx, sloggorm.TraceEvent{Operation: sloggorm.OpUpdate, Tables: []string{"b"}, Outcome: sloggorm.OutcomeError, Err: context.DeadlineExceeded})
+	c.ObserveTrace(ctx, sloggorm.TraceEvent{Operation: sloggorm.OpUpdate, Tables: []string{"e"}, Outcome: sloggorm.OutcomeError, Err: errors.New("boom")})
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.queries.WithLabelValues("SELECT", "a", "ok")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.queries.WithLabelValues("SELECT", "b", "ok")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.queries.WithLabelValues("SELECT", OtherLabel, "ok")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.queries.WithLabelValues(OtherLabel, "", "slow")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.errors.WithLabelValues("UPDATE", "b", sloggorm.ErrorClassDeadline)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.errors.WithLabelValues("UPDATE", OtherLabel, sloggorm.ErrorClassOther)))
+	assert.Equal(t, 6, testutil.CollectAndCount(c, "gorm_queries_total"))
+	assert.Equal(t, 0, testutil.CollectAndCount(c, "gorm_query_rows_total"))
+}
@@ -0,0 +1,221 @@
+package sloggorm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock provides the time to the Reporter, it can be replaced to drive the reports from tests
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a channel delivering the ticks at the given interval, and a function to stop it
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+// systemClock is the Clock of the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// NewReporter creates a reporter logging the top N queries of the logger every interval, by total duration, p95 latency and error count.
+//
+// It reads the statistics of the logger, which must be enabled with WithStats, without resetting them:
+//
+//	reporter := sloggorm.NewReporter(glogger, time.Minute)
+//	err := reporter.Start()
+//	defer reporter.Close()
+func NewReporter(l *logger, interval time.Duration) *Reporter {
+	return &Reporter{
+		logger:   l,
+		interval: interval,
+		topN:     5,
+		clock:    systemClock{},
+	}
+}
+
+// Reporter periodically logs the top N queries, see NewReporter
+type Reporter struct {
+	logger   *logger
+	interval time.Duration
+	topN     int
+	clock    Clock
+
+	last      StatsSnapshot
+	lastTime  time.Time
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// WithTopN set the number of queries listed per ranking. Default 5
+func (r *Reporter) WithTopN(n int) *Reporter {
+	r.topN = n
+	return r
+}
+
+// WithClock set the clock driving the reports. Default is the system clock
+func (r *Reporter) WithClock(c Clock) *Reporter {
+	r.clock = c
+	return r
+}
+
+// Start starts the reporter goroutine, which must be stopped with Close
+func (r *Reporter) Start() error {
+	if r.logger.stats == nil {
+		return errors.New("sloggorm: the statistics of the logger are disabled, see WithStats")
+	}
+	if r.interval <= 0 {
+		return errors.New("sloggorm: the report interval must be positive")
+	}
+
+	r.startOnce.Do(func() {
+		r.last, r.lastTime = r.logger.Stats(), r.clock.Now()
+		r.stop = make(chan struct{})
+		r.done = make(chan struct{})
+		ticks, stopTicker := r.clock.NewTicker(r.interval)
+		go func() {
+			defer close(r.done)
+			defer stopTicker()
+			for {
+				select {
+				case <-r.stop:
+					return
+				case now := <-ticks:
+					r.report(now)
+				}
+			}
+		}()
+	})
+	return nil
+}
+
+// Close stops the reporter goroutine and waits for it to return
+func (r *Reporter) Close() error {
+	if r.stop == nil {
+		return nil
+	}
+	r.closeOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+	return nil
+}
+
+// ReportEntry is a query listed in a report, with its statistics since the previous report.
+// The P95 is approximated from the latency histogram, see QueryStats.Percentile.
+type ReportEntry struct {
+	Fingerprint   string        `json:"fingerprint"`
+	Count         uint64        `json:"count"`
+	Errors        uint64        `json:"errors"`
+	TotalDuration time.Duration `json:"total_duration"`
+	P95           time.Duration `json:"p95"`
+}
+
+// report logs the top N queries since the previous report, if any statement has been traced
+func (r *Reporter) report(now time.Time) {
+	current := r.logger.Stats()
+	deltas := statsDelta(r.last, current)
+	period := now.Sub(r.lastTime)
+	r.last, r.lastTime = current, now
+
+	l := r.logger
+	ctx := context.Background()
+	if len(deltas) == 0 || !l.enabled(ctx, slog.LevelInfo) {
+		return
+	}
+
+	var statements uint64
+	var dbTime time.Duration
+	entries := make([]ReportEntry, len(deltas))
+	for i, q := range deltas {
+		statements += q.Count
+		dbTime += q.TotalDuration
+		entries[i] = ReportEntry{
+			Fingerprint:   q.Fingerprint,
+			Count:         q.Count,
+			Errors:        q.Errors,
+			TotalDuration: q.TotalDuration,
+			P95:           q.Percentile(95),
+		}
+	}
+
+	attrs := []slog.Attr{
+		slog.Duration("period", period),
+		slog.Uint64("statements", statements),
+		slog.Duration("db_time", dbTime),
+		slog.Any("top_total_duration", r.top(entries, func(a, b ReportEntry) bool { return a.TotalDuration > b.TotalDuration })),
+		slog.Any("top_p95", r.top(entries, func(a, b ReportEntry) bool { return a.P95 > b.P95 })),
+	}
+	if errs := r.top(entries, func(a, b ReportEntry) bool { return a.Errors > b.Errors }); len(errs) > 0 && errs[0].Errors > 0 {
+		for len(errs) > 0 && errs[len(errs)-1].Errors == 0 {
+			errs = errs[:len(errs)-1]
+		}
+		attrs = append(attrs, slog.Any("top_errors", errs))
+	}
+	l.log(ctx, slog.LevelInfo, l.reportMsg, l.recordAttrs(ctx, attrs)...)
+}
+
+// top returns the first N entries by the given order, ties broken by fingerprint
+func (r *Reporter) top(entries []ReportEntry, less func(a, b ReportEntry) bool) []ReportEntry {
+	sorted := append([]ReportEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if less(sorted[i], sorted[j]) {
+			return true
+		}
+		if less(sorted[j], sorted[i]) {
+			return false
+		}
+		return sorted[i].Fingerprint < sorted[j].Fingerprint
+	})
+	if len(sorted) > r.topN {
+		sorted = sorted[:r.topN]
+	}
+	return sorted
+}
+
+// statsDelta returns the statistics of the queries executed between the given snapshots,
+// or the current ones if the statistics have been reset in between
+func statsDelta(prev, current StatsSnapshot) []QueryStats {
+	if !prev.Since.Equal(current.Since) {
+		prev = StatsSnapshot{}
+	}
+	previous := make(map[string]QueryStats, len(prev.Queries))
+	for _, q := range prev.Queries {
+		previous[q.Fingerprint] = q
+	}
+
+	var deltas []QueryStats
+	for _, q := range current.Queries {
+		p, ok := previous[q.Fingerprint]
+		if !ok {
+			deltas = append(deltas, q)
+			continue
+		}
+		if q.Count == p.Count {
+			continue
+		}
+		d := q
+		d.Count -= p.Count
+		d.Errors -= p.Errors
+		d.Rows -= p.Rows
+		d.TotalDuration -= p.TotalDuration
+		d.Histogram = make([]uint64, len(q.Histogram))
+		for i := range q.Histogram {
+			d.Histogram[i] = q.Histogram[i] - p.Histogram[i]
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
@@ -0,0 +1,136 @@
+package sloggorm
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock driven by the tests
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	ticks   chan time.Time
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC), ticks: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(time.Duration) (<-chan time.Time, func()) {
+	return c.ticks, func() {
+		c.mu.Lock()
+		c.stopped = true
+		c.mu.Unlock()
+	}
+}
+
+// tick advances the clock and waits for the tick to be received
+func (c *fakeClock) tick(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	c.ticks <- now
+}
+
+func TestReporter(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithStats(true))
+	db := openTestDB(t, NewPlugin(l))
+	require.NoError(t, db.Create(&testCompany{Name: "a"}).Error)
+	buf.Reset()
+
+	clock := newFakeClock()
+	r := NewReporter(l, time.Minute).WithTopN(2).WithClock(clock)
+	require.NoError(t, r.Start())
+	require.NoError(t, r.Start())
+
+	t.Run("report since the start", func(t *testing.T) {
+		var companies []testCompany
+		for i := 0; i < 3; i++ {
+			db.Where("id = ?", i).Find(&companies)
+		}
+		db.Exec("SELECT * FROM missing")
+		db.Create(&testCompany{Name: "b"})
+		clock.tick(time.Minute)
+		clock.tick(time.Minute) // waits for the previous report
+
+		records := buf.records(t)
+		require.Len(t, records, 2)
+		assert.Equal(t, "Query ERROR", records[0]["msg"])
+		report := records[1]
+		assert.Equal(t, "Query REPORT", report["msg"])
+		assert.Equal(t, float64(time.Minute), report["period"])
+		assert.Equal(t, float64(5), report["statements"])
+		assert.Len(t, report["top_total_duration"], 2)
+		assert.Len(t, report["top_p95"], 2)
+
+		errs := report["top_errors"].([]any)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "SELECT * FROM missing", errs[0].(map[string]any)["fingerprint"])
+		assert.Equal(t, float64(1), errs[0].(map[string]any)["errors"])
+	})
+
+	t.Run("delta since the previous report", func(t *testing.T) {
+		var companies []testCompany
+		db.Where("id = ?", 42).Find(&companies)
+		clock.tick(time.Minute)
+		clock.tick(2 * time.Minute)
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, float64(time.Minute), records[0]["period"])
+		assert.Equal(t, float64(1), records[0]["statements"])
+		top := records[0]["top_total_duration"].([]any)
+		require.Len(t, top, 1)
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE id = ?", top[0].(map[string]any)["fingerprint"])
+		assert.Equal(t, float64(1), top[0].(map[string]any)["count"])
+		assert.NotContains(t, records[0], "top_errors")
+	})
+
+	t.Run("stats reset", func(t *testing.T) {
+		var companies []testCompany
+		db.Find(&companies)
+		l.ResetStats()
+		db.Find(&companies)
+		clock.tick(time.Minute)
+
+		require.NoError(t, r.Close())
+		require.NoError(t, r.Close())
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, float64(1), records[0]["statements"])
+	})
+
+	select {
+	case <-r.done:
+	default:
+		t.Error("reporter goroutine is still running")
+	}
+	clock.mu.Lock()
+	assert.True(t, clock.stopped)
+	clock.mu.Unlock()
+}
+
+func TestReporter_Start(t *testing.T) {
+	assert.Error(t, NewReporter(New(), time.Minute).Start())
+	assert.Error(t, NewReporter(NewWithConfig(NewConfig(New().slogHandler).WithStats(true)), 0).Start())
+	assert.NoError(t, NewReporter(New(), time.Minute).Close())
+
+	// the system clock
+	r := NewReporter(NewWithConfig(NewConfig(New().slogHandler).WithStats(true)), time.Millisecond)
+	require.NoError(t, r.Start())
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, r.Close())
+}
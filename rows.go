@@ -0,0 +1,99 @@
+package sloggorm
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// checksRows reports whether the rows of the statements are checked, see WithRowsThreshold and WithOptimisticLockColumns
+func (l *logger) checksRows() bool {
+	return l.rowsThreshold > 0 || len(l.operationRowsThresholds) > 0 || len(l.tableRowsThresholds) > 0 || len(l.optimisticLockColumns) > 0
+}
+
+// rowsThresholdOf returns the rows threshold of a statement, 0 if none. The table one takes precedence over the operation one, then the global one.
+func (l *logger) rowsThresholdOf(operation, table string) int64 {
+	if v, ok := l.tableRowsThresholds[strings.ToLower(table)]; ok && table != "" {
+		return v
+	}
+	if v, ok := l.operationRowsThresholds[operation]; ok {
+		return v
+	}
+	return l.rowsThreshold
+}
+
+// traceRows warns about the SELECTs returning, or the UPDATEs and DELETEs affecting, more rows than expected,
+// and about the UPDATEs of no rows with an optimistic-lock column in their WHERE clause
+func (l *logger) traceRows(ctx context.Context, sql string, rows int64, file string, err error) {
+	info := stmtInfoFrom(ctx)
+	if err != nil || rows < 0 || (info != nil && info.dryRun) || !l.enabled(ctx, slog.LevelWarn) {
+		return
+	}
+	parsed := info.sqlInfo(sql)
+	table := firstTable(parsed.tables)
+
+	switch parsed.operation {
+	case OpSelect, OpUpdate, OpDelete:
+	default:
+		return
+	}
+	if threshold := l.rowsThresholdOf(parsed.operation, table); threshold > 0 && rows > threshold {
+		attrs := make([]slog.Attr, 0, 8)
+		if l.rowsKey != "" {
+			attrs = append(attrs, slog.Int64(l.rowsKey, rows))
+		}
+		attrs = append(attrs, slog.Int64("rows_threshold", threshold))
+		if l.operationKey != "" {
+			attrs = append(attrs, slog.String(l.operationKey, parsed.operation))
+		}
+		if l.tableKey != "" && table != "" {
+			attrs = append(attrs, slog.Any(l.tableKey, []string{table}))
+		}
+		attrs = l.appendSource(attrs, file)
+		if l.queryKey != "" {
+			attrs = append(attrs, slog.String(l.queryKey, sql))
+		}
+		l.log(ctx, slog.LevelWarn, l.rowsExceededMsg, l.recordAttrs(ctx, attrs)...)
+	}
+
+	if parsed.operation == OpUpdate && rows == 0 && len(l.optimisticLockColumns) > 0 {
+		// the SQL built by gorm has placeholders, the inlined strings may be quoted as identifiers otherwise
+		built := sql
+		if info != nil && info.sql != "" {
+			built = info.sql
+		}
+		if columns := l.lockColumns(built); len(columns) > 0 {
+			attrs := make([]slog.Attr, 0, 6)
+			if l.tableKey != "" && table != "" {
+				attrs = append(attrs, slog.Any(l.tableKey, []string{table}))
+			}
+			attrs = append(attrs, slog.Any("lock_columns", columns))
+			attrs = l.appendSource(attrs, file)
+			if l.queryKey != "" {
+				attrs = append(attrs, slog.String(l.queryKey, sql))
+			}
+			l.log(ctx, slog.LevelWarn, l.staleUpdateMsg, l.recordAttrs(ctx, attrs)...)
+		}
+	}
+}
+
+// lockColumns returns the optimistic-lock columns referenced by the top-level WHERE clause of a statement
+func (l *logger) lockColumns(sql string) []string {
+	var columns []string
+	where := false
+	for _, t := range topLevel(tokenizeSQL(sql)) {
+		switch {
+		case t.is("WHERE"):
+			where = true
+		case t.is("RETURNING") || t.is("ORDER") || t.is("LIMIT"):
+			where = false
+		case where && (t.kind == tokWord || t.kind == tokQuotedIdent):
+			name := strings.ToLower(unquoteIdent(t.text))
+			if l.optimisticLockColumns[name] && !slices.Contains(columns, name) {
+				columns = append(columns, name)
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,120 @@
+package sloggorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func Test_logger_rowsThresholdOf(t *testing.T) {
+	l := NewWithConfig(NewConfig(New().slogHandler).
+		WithRowsThreshold(100).
+		WithOperationRowsThresholds(map[string]int64{"select": 1000, OpDelete: 10}).
+		WithTableRowsThresholds(map[string]int64{"Events": 5000, "audit_logs": 0}))
+
+	tests := []struct {
+		operation string
+		table     string
+		want      int64
+	}{
+		{OpUpdate, "users", 100},
+		{OpSelect, "users", 1000},
+		{OpDelete, "", 10},
+		{OpSelect, "events", 5000},
+		{OpDelete, "EVENTS", 5000},
+		{OpSelect, "audit_logs", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operation+" "+tt.table, func(t *testing.T) {
+			assert.Equal(t, tt.want, l.rowsThresholdOf(tt.operation, tt.table))
+		})
+	}
+}
+
+func Test_logger_lockColumns(t *testing.T) {
+	l := NewWithConfig(NewConfig(New().slogHandler).WithOptimisticLockColumns("version", "Revision"))
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"UPDATE users SET name = ? WHERE id = ? AND version = ?", []string{"version"}},
+		{"UPDATE `users` SET `version` = ? WHERE `users`.`id` = ? AND `users`.`revision` = ?", []string{"revision"}},
+		{"UPDATE users SET version = version + 1 WHERE id = ?", nil},
+		{"UPDATE users SET name = ? WHERE id IN (SELECT id FROM events WHERE version = ?)", nil},
+		{"UPDATE users SET name = ? WHERE version = ? AND Version < ? RETURNING version", []string{"version"}},
+		{"UPDATE users SET name = ?", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.want, l.lockColumns(tt.sql))
+		})
+	}
+}
+
+func TestWithRowsThreshold(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).
+		WithRowsThreshold(2).
+		WithTableRowsThresholds(map[string]int64{"test_companies": 0}).
+		WithOptimisticLockColumns("age").
+		WithOperationKey("operation").
+		WithTableKey("table"))
+	db := openTestDB(t, NewPlugin(l))
+	require.NoError(t, db.Create([]testUser{{Name: "a"}, {Name: "b"}, {Name: "c"}}).Error)
+	require.NoError(t, db.Create([]testCompany{{Name: "a"}, {Name: "b"}, {Name: "c"}}).Error)
+	buf.Reset()
+
+	var users []testUser
+	require.NoError(t, db.Find(&users).Error)
+	var companies []testCompany
+	require.NoError(t, db.Find(&companies).Error)                                        // disabled for the table
+	require.NoError(t, db.Limit(2).Find(&users).Error)                                   // within the threshold
+	require.NoError(t, db.Model(&testUser{}).Where("age = ?", 0).Update("age", 1).Error) // 3 rows affected
+	require.NoError(t, db.Model(&testUser{}).Where("id = ? AND age = ?", 1, 0).Update("name", "z").Error)
+
+	records := buf.records(t)
+	require.Len(t, records, 3)
+	assert.Equal(t, map[string]any{
+		"time":           records[0]["time"],
+		"level":          "WARN",
+		"msg":            "Query ROWS EXCEEDED",
+		"rows":           float64(3),
+		"rows_threshold": float64(2),
+		"operation":      OpSelect,
+		"table":          []any{"test_users"},
+		"file":           records[0]["file"],
+		"query":          "SELECT * FROM `test_users`",
+	}, records[0])
+	assert.Contains(t, records[0]["file"], "rows_test.go")
+	assert.Equal(t, "Query ROWS EXCEEDED", records[1]["msg"])
+	assert.Equal(t, OpUpdate, records[1]["operation"])
+	assert.Equal(t, map[string]any{
+		"time":         records[2]["time"],
+		"level":        "WARN",
+		"msg":          "Query STALE UPDATE",
+		"table":        []any{"test_users"},
+		"lock_columns": []any{"age"},
+		"file":         records[2]["file"],
+		"query":        records[2]["query"],
+	}, records[2])
+
+	t.Run("without operation and table keys", func(t *testing.T) {
+		l := NewWithConfig(NewConfig(buf.handler()).WithRowsThreshold(2))
+		l.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT * FROM test_users", 3 }, nil)
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "Query ROWS EXCEEDED", records[0]["msg"])
+		assert.NotContains(t, records[0], "operation")
+		assert.NotContains(t, records[0], "table")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		require.NoError(t, db.Session(&gorm.Session{DryRun: true}).Find(&users).Error)
+		assert.Empty(t, buf.records(t))
+	})
+}
@@ -0,0 +1,311 @@
+package sloggorm
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxScopeSources is the max number of distinct source locations kept per query of a scope
+	maxScopeSources = 5
+	// maxScopeDuplicates is the max number of distinct statements tracked for duplicates per scope, the next ones are not tracked
+	maxScopeDuplicates = 1000
+)
+
+type scopeKey struct{}
+
+// queryScope accumulates the statements traced within a request or job context, see WithQueryScope
+type queryScope struct {
+	start time.Time
+
+	mu         sync.Mutex
+	logger     *logger                // the logger which traced the statements, used for the summary
+	queries    map[string]*scopeQuery // by fingerprint
+	operations map[string]int
+	dbTime     time.Duration
+	errors     int
+	rowsRead   int64
+	rowsWrite  int64
+	slowest    scopeSlowest
+	duplicates map[uint64]*scopeDuplicate // by statement hash, see statementHash
+	failed     bool
+	buffered   []bufferedRecord // see WithTailBuffer
+	dropped    int
+}
+
+// scopeQuery accumulates the executions of a normalized query within a scope
+type scopeQuery struct {
+	count   int
+	total   time.Duration
+	sources []string
+	warned  bool
+}
+
+// scopeDuplicate accumulates the executions of an identical statement within a scope, without retaining its params
+type scopeDuplicate struct {
+	count  int
+	source string // the first call site
+}
+
+// scopeSlowest is the slowest statement of a scope
+type scopeSlowest struct {
+	elapsed time.Duration
+	sql     string
+	source  string
+}
+
+func newQueryScope() *queryScope {
+	return &queryScope{
+		start:      time.Now(),
+		queries:    map[string]*scopeQuery{},
+		operations: map[string]int{},
+		duplicates: map[uint64]*scopeDuplicate{},
+	}
+}
+
+// WithQueryScope returns a copy of ctx starting a new query scope, e.g. per HTTP request or background job.
+//
+// The statements executed with the returned context, see gorm.DB.WithContext, are accounted to the scope,
+// to detect N+1 queries, see WithNPlusOneThreshold.
+func WithQueryScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, newQueryScope())
+}
+
+// StartScope is like WithQueryScope, it also returns a function to call at the end of the scope,
+// which logs a summary of the statements, if any:
+//
+//	ctx, finish := sloggorm.StartScope(ctx)
+//	defer finish()
+func StartScope(ctx context.Context) (context.Context, func()) {
+	s := newQueryScope()
+	ctx = context.WithValue(ctx, scopeKey{}, s)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() { s.finish(ctx) })
+	}
+}
+
+// Middleware returns a net/http middleware starting a query scope per request, see StartScope.
+// The scope is marked as failed on 5xx responses and panics, see MarkFailed.
+//
+// The summary has the context attributes of the request, so the middleware should be installed after the ones setting them.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, finish := StartScope(r.Context())
+		sw := &statusWriter{ResponseWriter: w}
+		completed := false
+		defer func() {
+			if !completed || sw.status >= http.StatusInternalServerError {
+				MarkFailed(ctx)
+			}
+			finish()
+		}()
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		completed = true
+	})
+}
+
+// scopeFrom returns the query scope of the context, nil if none
+func scopeFrom(ctx context.Context) *queryScope {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(scopeKey{}).(*queryScope)
+	return s
+}
+
+// traceScope accounts a traced statement to the scope, and warns about N+1 queries
+func (l *logger) traceScope(ctx context.Context, s *queryScope, elapsed time.Duration, sql string, rows int64, file string, err error) {
+	operation := stmtInfoFrom(ctx).sqlInfo(sql).operation
+
+	s.mu.Lock()
+	if s.logger == nil || s.logger.silent {
+		s.logger = l
+	}
+	s.operations[operation]++
+	s.dbTime += elapsed
+	if l.failed(err) {
+		s.errors++
+	}
+	if rows > 0 {
+		if operation == OpSelect {
+			s.rowsRead += rows
+		} else {
+			s.rowsWrite += rows
+		}
+	}
+	if elapsed > s.slowest.elapsed || s.slowest.sql == "" {
+		s.slowest = scopeSlowest{elapsed: elapsed, sql: sql, source: file}
+	}
+	s.mu.Unlock()
+
+	if l.nPlusOneThreshold > 0 {
+		l.traceNPlusOne(ctx, s, elapsed, sql, file)
+	}
+	if l.duplicateQueries && err == nil && operation == OpSelect {
+		l.traceDuplicate(ctx, s, sql, file)
+	}
+}
+
+// traceNPlusOne accounts a traced statement by fingerprint, and warns the first time it runs more than the threshold
+func (l *logger) traceNPlusOne(ctx context.Context, s *queryScope, elapsed time.Duration, sql string, file string) {
+	fp := stmtInfoFrom(ctx).fingerprint(sql)
+	s.mu.Lock()
+	q := s.queries[fp]
+	if q == nil {
+		q = &scopeQuery{}
+		s.queries[fp] = q
+	}
+	q.count++
+	q.total += elapsed
+	if file != "" && len(q.sources) < maxScopeSources && !slices.Contains(q.sources, file) {
+		q.sources = append(q.sources, file)
+	}
+	warn := !q.warned && q.count > l.nPlusOneThreshold
+	if warn {
+		q.warned = true
+	}
+	count, total, sources := q.count, q.total, append([]string(nil), q.sources...)
+	s.mu.Unlock()
+
+	if !warn || !l.enabled(ctx, slog.LevelWarn) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 5)
+	attrs = append(attrs, slog.Int("count", count), slog.Duration("total_duration", total))
+	if l.sourceKey != "" {
+		if !l.fullSourcePath {
+			for i, source := range sources {
+				sources[i] = path.Base(source)
+			}
+		}
+		attrs = append(attrs, slog.Any("sources", sources))
+	}
+	attrs = append(attrs, slog.String("fingerprint", fp))
+	if l.queryKey != "" {
+		attrs = append(attrs, slog.String(l.queryKey, sql))
+	}
+	l.log(ctx, slog.LevelWarn, l.nPlusOneMsg, l.recordAttrs(ctx, attrs)...)
+}
+
+// traceDuplicate accounts a traced query by hash, and reports it the first time it's executed again with the same params
+func (l *logger) traceDuplicate(ctx context.Context, s *queryScope, sql string, file string) {
+	var hash uint64
+	if info := stmtInfoFrom(ctx); info != nil && info.hash != 0 {
+		hash = info.hash
+	} else if l.parameterizedQueries {
+		// the params are not known
+		return
+	} else {
+		hash = statementHash(sql, nil)
+	}
+
+	s.mu.Lock()
+	d := s.duplicates[hash]
+	if d == nil {
+		if len(s.duplicates) >= maxScopeDuplicates {
+			// e.g. a job reading rows one by one
+			s.mu.Unlock()
+			return
+		}
+		d = &scopeDuplicate{source: file}
+		s.duplicates[hash] = d
+	}
+	d.count++
+	count, first := d.count, d.source
+	s.mu.Unlock()
+
+	if count != 2 || !l.enabled(ctx, slog.LevelWarn) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 4)
+	attrs = append(attrs, slog.Int("count", count))
+	if l.sourceKey != "" {
+		sources := []string{first, file}
+		if !l.fullSourcePath {
+			for i, source := range sources {
+				sources[i] = path.Base(source)
+			}
+		}
+		attrs = append(attrs, slog.Any("sources", sources))
+	}
+	attrs = append(attrs, slog.String("fingerprint", stmtInfoFrom(ctx).fingerprint(sql)))
+	if l.queryKey != "" {
+		attrs = append(attrs, slog.String(l.queryKey, sql))
+	}
+	l.log(ctx, slog.LevelWarn, l.duplicateMsg, l.recordAttrs(ctx, attrs)...)
+}
+
+// hashSeed is the seed of the statement hashes, see statementHash
+var hashSeed = maphash.MakeSeed()
+
+// statementHash returns the hash of a statement with its params, to detect duplicates without retaining the params
+func statementHash(sql string, vars []any) uint64 {
+	var h maphash.Hash
+	h.SetSeed(hashSeed)
+	_, _ = h.WriteString(sql)
+	for _, v := range vars {
+		_ = h.WriteByte(0)
+		_, _ = fmt.Fprintf(&h, "%T:%v", v, v)
+	}
+	return h.Sum64()
+}
+
+// finish logs the summary of the scope, if any statement has been traced
+func (s *queryScope) finish(ctx context.Context) {
+	s.discard()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.logger
+	if l == nil || !l.enabled(ctx, slog.LevelInfo) {
+		return
+	}
+
+	statements := 0
+	operations := make([]string, 0, len(s.operations))
+	for op, n := range s.operations {
+		statements += n
+		operations = append(operations, op)
+	}
+	sort.Strings(operations)
+	counts := make([]slog.Attr, 0, len(operations))
+	for _, op := range operations {
+		name := op
+		if name == "" {
+			name = "OTHER"
+		}
+		counts = append(counts, slog.Int(name, s.operations[op]))
+	}
+
+	attrs := make([]slog.Attr, 0, 9)
+	if l.durationKey != "" {
+		attrs = append(attrs, slog.Duration(l.durationKey, time.Since(s.start)))
+	}
+	attrs = append(attrs,
+		slog.Int("statements", statements),
+		slog.Attr{Key: "operations", Value: slog.GroupValue(counts...)},
+		slog.Duration("db_time", s.dbTime),
+		slog.Int("errors", s.errors),
+		slog.Int64("rows_read", s.rowsRead),
+		slog.Int64("rows_affected", s.rowsWrite),
+	)
+	slowest := []slog.Attr{slog.Duration("duration", s.slowest.elapsed)}
+	if s.slowest.source != "" {
+		slowest = l.appendSource(slowest, s.slowest.source)
+	}
+	if l.queryKey != "" {
+		slowest = append(slowest, slog.String(l.queryKey, s.slowest.sql))
+	}
+	attrs = append(attrs, slog.Attr{Key: "slowest", Value: slog.GroupValue(slowest...)})
+	l.log(ctx, slog.LevelInfo, l.summaryMsg, l.recordAttrs(ctx, attrs)...)
+}
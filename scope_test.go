@@ -0,0 +1,242 @@
+package sloggorm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestWithQueryScope(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithNPlusOneThreshold(2))
+	db := openTestDB(t, NewPlugin(l))
+	require.NoError(t, db.Create(&[]testUser{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}).Error)
+	buf.Reset()
+
+	t.Run("N+1", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		var users []testUser
+		require.NoError(t, db.WithContext(ctx).Find(&users).Error)
+
+		_, _, line, _ := runtime.Caller(0)
+		for _, u := range users {
+			var company testCompany
+			db.WithContext(ctx).Where("id = ?", u.ID).Limit(1).Find(&company)
+		}
+		var company testCompany
+		db.WithContext(ctx).Where("id = ?", 42).Limit(1).Find(&company)
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		r := records[0]
+		assert.Equal(t, "WARN", r["level"])
+		assert.Equal(t, "Query N+1", r["msg"])
+		assert.Equal(t, float64(3), r["count"]) // reported once crossed
+		assert.Greater(t, r["total_duration"], float64(0))
+		assert.Equal(t, []any{"scope_test.go:" + strconv.Itoa(line+3)}, r["sources"])
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE id = ? LIMIT ?", r["fingerprint"])
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE id = 3 LIMIT 1", r["query"])
+	})
+
+	t.Run("below threshold", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		for i := 0; i < 2; i++ {
+			var company testCompany
+			db.WithContext(ctx).Where("id = ?", i).Limit(1).Find(&company)
+		}
+		assert.Empty(t, buf.records(t))
+	})
+
+	t.Run("per scope", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			var company testCompany
+			db.WithContext(WithQueryScope(context.Background())).Where("id = ?", i).Limit(1).Find(&company)
+		}
+		assert.Empty(t, buf.records(t))
+	})
+
+	t.Run("without scope", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			var company testCompany
+			db.Where("id = ?", i).Limit(1).Find(&company)
+		}
+		assert.Empty(t, buf.records(t))
+	})
+
+	t.Run("silent", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		silent := db.Session(&gorm.Session{Logger: l.LogMode(gormlogger.Silent)}).WithContext(ctx)
+		for i := 0; i < 4; i++ {
+			var company testCompany
+			silent.Where("id = ?", i).Limit(1).Find(&company)
+		}
+		assert.Empty(t, buf.records(t))
+		assert.Equal(t, 4, scopeFrom(ctx).queries["SELECT * FROM `test_companies` WHERE id = ? LIMIT ?"].count)
+	})
+}
+
+type scopeCtxKey struct{}
+
+func TestStartScope(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithContextKeys(map[string]any{"req_id": scopeCtxKey{}}))
+	db := openTestDB(t, NewPlugin(l))
+	buf.Reset()
+
+	t.Run("summary", func(t *testing.T) {
+		ctx, finish := StartScope(context.WithValue(context.Background(), scopeCtxKey{}, "abc"))
+		require.NoError(t, db.WithContext(ctx).Create(&[]testCompany{{Name: "a"}, {Name: "b"}}).Error)
+		var companies []testCompany
+		require.NoError(t, db.WithContext(ctx).Find(&companies).Error)
+		require.NoError(t, db.WithContext(ctx).Model(&testCompany{}).Where("id = ?", 1).Update("name", "c").Error)
+		var count int64
+		require.NoError(t, db.WithContext(ctx).Model(&testCompany{}).Count(&count).Error)
+		assert.Error(t, db.WithContext(ctx).Exec("SELECT * FROM missing").Error)
+		buf.Reset()
+
+		finish()
+		finish()
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		r := records[0]
+		assert.Equal(t, "INFO", r["level"])
+		assert.Equal(t, "Query SUMMARY", r["msg"])
+		assert.Equal(t, "abc", r["req_id"])
+		assert.Equal(t, float64(5), r["statements"])
+		assert.Equal(t, map[string]any{"INSERT": float64(1), "SELECT": float64(3), "UPDATE": float64(1)}, r["operations"])
+		assert.Equal(t, float64(1), r["errors"])
+		assert.Equal(t, float64(3), r["rows_read"])
+		assert.Equal(t, float64(3), r["rows_affected"])
+		assert.Greater(t, r["db_time"], float64(0))
+		assert.GreaterOrEqual(t, r["duration"], r["db_time"])
+		slowest := r["slowest"].(map[string]any)
+		assert.Contains(t, slowest, "duration")
+		assert.Contains(t, slowest, "query")
+	})
+
+	t.Run("no statement", func(t *testing.T) {
+		_, finish := StartScope(context.Background())
+		finish()
+		assert.Empty(t, buf.records(t))
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var company testCompany
+			db.WithContext(r.Context()).First(&company)
+		}))
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		h.ServeHTTP(rec, req.WithContext(context.WithValue(req.Context(), scopeCtxKey{}, "xyz")))
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "Query SUMMARY", records[0]["msg"])
+		assert.Equal(t, "xyz", records[0]["req_id"])
+		assert.Equal(t, float64(1), records[0]["statements"])
+		assert.Equal(t, "SELECT * FROM `test_companies` ORDER BY `test_companies`.`id` LIMIT 1", records[0]["slowest"].(map[string]any)["query"])
+	})
+}
+
+func TestWithDuplicateQueries(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()))
+	db := openTestDB(t, NewPlugin(l))
+	require.NoError(t, db.Create(&testCompany{Name: "gorm"}).Error)
+	buf.Reset()
+
+	loadCompany := func(ctx context.Context, name string) {
+		var company testCompany
+		db.WithContext(ctx).Where("name = ?", name).Limit(1).Find(&company)
+	}
+
+	t.Run("duplicates", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		_, _, line, _ := runtime.Caller(0)
+		loadCompany(ctx, "gorm")
+		loadCompany(ctx, "other")
+		loadCompany(ctx, "gorm")
+		loadCompany(ctx, "gorm")
+
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		r := records[0]
+		assert.Equal(t, "WARN", r["level"])
+		assert.Equal(t, "Query DUPLICATE", r["msg"])
+		assert.Equal(t, float64(2), r["count"])
+		// the call sites of the helper
+		assert.Equal(t, []any{"scope_test.go:" + strconv.Itoa(line-5), "scope_test.go:" + strconv.Itoa(line-5)}, r["sources"])
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = ? LIMIT ?", r["fingerprint"])
+		assert.Equal(t, "SELECT * FROM `test_companies` WHERE name = \"gorm\" LIMIT 1", r["query"])
+	})
+
+	t.Run("not retaining params", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		loadCompany(ctx, "secret")
+		for hash, d := range scopeFrom(ctx).duplicates {
+			assert.NotZero(t, hash)
+			assert.NotContains(t, d.source, "secret")
+		}
+	})
+
+	t.Run("writes", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		for i := 0; i < 2; i++ {
+			require.NoError(t, db.WithContext(ctx).Model(&testCompany{}).Where("id = ?", 1).Update("name", "gorm").Error)
+		}
+		assert.Empty(t, buf.records(t))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var buf logBuffer
+		db := openTestDB(t, NewPlugin(NewWithConfig(NewConfig(buf.handler()).WithDuplicateQueries(false))))
+		ctx := WithQueryScope(context.Background())
+		for i := 0; i < 2; i++ {
+			var company testCompany
+			db.WithContext(ctx).Where("name = ?", "gorm").Find(&company)
+		}
+		assert.Empty(t, buf.records(t))
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		trace := func(id int) {
+			l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT * FROM companies WHERE id = " + strconv.Itoa(id), 1 }, nil)
+		}
+		for i := 0; i < maxScopeDuplicates; i++ {
+			trace(i)
+		}
+		trace(maxScopeDuplicates)
+		trace(maxScopeDuplicates) // not tracked
+		trace(0)                  // still tracked
+		assert.Len(t, scopeFrom(ctx).duplicates, maxScopeDuplicates)
+
+		var duplicates []map[string]any
+		for _, r := range buf.records(t) {
+			if r["msg"] == "Query DUPLICATE" {
+				duplicates = append(duplicates, r)
+			}
+		}
+		require.Len(t, duplicates, 1)
+		assert.Equal(t, "SELECT * FROM companies WHERE id = 0", duplicates[0]["query"])
+	})
+
+	t.Run("without plugin", func(t *testing.T) {
+		ctx := WithQueryScope(context.Background())
+		sql := "SELECT * FROM companies WHERE id = 1"
+		l.Trace(ctx, time.Now(), func() (string, int64) { return sql, 1 }, nil)
+		l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT * FROM companies WHERE id = 2", 1 }, nil)
+		assert.Empty(t, buf.records(t))
+		l.Trace(ctx, time.Now(), func() (string, int64) { return sql, 1 }, nil)
+		assert.Len(t, buf.records(t), 1)
+	})
+}
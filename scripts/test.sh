@@ -1,4 +1,9 @@
 #!/usr/bin/env bash
+set -e
 
 go install gotest.tools/gotestsum@latest
+# the root module, then the nested ones, e.g. the exporters with their own dependencies
 gotestsum --format testname -- -race -coverprofile=cover.out $(go list ./...)
+for mod in $(find . -mindepth 2 -name go.mod -exec dirname {} \;); do
+	(cd "$mod" && gotestsum --format testname -- -race -coverprofile=cover.out ./...)
+done
@@ -0,0 +1,529 @@
+package sloggorm
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SQL operations reported in the operation attribute
+const (
+	OpSelect = "SELECT"
+	OpInsert = "INSERT"
+	OpUpdate = "UPDATE"
+	OpDelete = "DELETE"
+	OpDDL    = "DDL"
+	OpExec   = "EXEC"
+	OpRaw    = "RAW"
+)
+
+type tokenKind uint8
+
+const (
+	tokWord        tokenKind = iota // keyword or bare identifier
+	tokQuotedIdent                  // "ident", `ident`
+	tokString                       // 'string', $$string$$
+	tokNumber                       // 123, 1.5e3, 0x1F
+	tokPlaceholder                  // ?, $1, @name
+	tokPunct                        // any other single character
+)
+
+// sqlToken is a lexical token of a SQL statement
+type sqlToken struct {
+	kind tokenKind
+	text string
+}
+
+// is reports whether the token is the given keyword, case-insensitively
+func (t sqlToken) is(keyword string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, keyword)
+}
+
+// isPunct reports whether the token is the given punctuation
+func (t sqlToken) isPunct(p string) bool {
+	return t.kind == tokPunct && t.text == p
+}
+
+// tokenizeSQL splits a SQL statement into tokens, skipping whitespaces and comments.
+//
+// It's tolerant by design: malformed input such as unterminated strings or comments never fails, the remaining text is
+// simply consumed by the last token.
+func tokenizeSQL(sql string) []sqlToken {
+	tokens := make([]sqlToken, 0, len(sql)/4)
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			i += lineEnd(sql[i:])
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(sql)
+			}
+		case c == '\'':
+			n := quotedEnd(sql[i:], '\'')
+			tokens = append(tokens, sqlToken{tokString, sql[i : i+n]})
+			i += n
+		case c == '"' || c == '`':
+			n := quotedEnd(sql[i:], c)
+			tokens = append(tokens, sqlToken{tokQuotedIdent, sql[i : i+n]})
+			i += n
+		case c == '?':
+			tokens = append(tokens, sqlToken{tokPlaceholder, "?"})
+			i++
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			n := 1 + spanOf(sql[i+1:], isDigit)
+			tokens = append(tokens, sqlToken{tokPlaceholder, sql[i : i+n]})
+			i += n
+		case c == '$':
+			if n := dollarQuotedEnd(sql[i:]); n > 0 {
+				tokens = append(tokens, sqlToken{tokString, sql[i : i+n]})
+				i += n
+			} else {
+				tokens = append(tokens, sqlToken{tokPunct, "$"})
+				i++
+			}
+		case c == '@' && i+1 < len(sql) && isWordByte(sql[i+1]):
+			n := 1 + wordEnd(sql[i+1:])
+			tokens = append(tokens, sqlToken{tokPlaceholder, sql[i : i+n]})
+			i += n
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			n := numberEnd(sql[i:])
+			tokens = append(tokens, sqlToken{tokNumber, sql[i : i+n]})
+			i += n
+		case isWordByte(c):
+			n := wordEnd(sql[i:])
+			tokens = append(tokens, sqlToken{tokWord, sql[i : i+n]})
+			i += n
+		default:
+			_, n := utf8.DecodeRuneInString(sql[i:])
+			tokens = append(tokens, sqlToken{tokPunct, sql[i : i+n]})
+			i += n
+		}
+	}
+	return tokens
+}
+
+// fingerprint normalizes a SQL statement, so the statements only differing by their values share the same fingerprint.
+//
+// Comments are stripped, whitespaces are collapsed, literals and placeholders are replaced by ?, and lists of them are
+// collapsed to a single one, e.g. IN (?) or VALUES (?).
+func fingerprint(sql string) string {
+	tokens := tokenizeSQL(sql)
+	out := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		text := t.text
+		switch t.kind {
+		case tokString, tokNumber, tokPlaceholder:
+			if n := len(out); n >= 2 && out[n-1] == "," && out[n-2] == "?" {
+				// lists of values
+				out = out[:n-1]
+				continue
+			}
+			text = "?"
+		}
+		out = append(out, text)
+	}
+
+	// lists of tuples
+	res := out[:0]
+	for i := 0; i < len(out); i++ {
+		if n := len(res); n >= 4 && res[n-1] == "," && res[n-2] == ")" && res[n-3] == "?" && res[n-4] == "(" &&
+			i+2 < len(out) && out[i] == "(" && out[i+1] == "?" && out[i+2] == ")" {
+			res = res[:n-1]
+			i += 2
+			continue
+		}
+		res = append(res, out[i])
+	}
+
+	var b strings.Builder
+	b.Grow(len(sql))
+	for i, text := range res {
+		if i > 0 && needsSpace(res[i-1], text) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// needsSpace reports whether a space is needed between the given normalized tokens
+func needsSpace(prev, next string) bool {
+	switch {
+	case next == "," || next == ")" || next == "." || prev == "(" || prev == ".":
+		return false
+	case next == "(":
+		// no space for function calls only
+		return !isWordByte(prev[0]) || isReservedWord(prev) || parenKeywords[strings.ToUpper(prev)]
+	case prev == ":" || next == ":":
+		// casts and named params, e.g. ::text, :name
+		return false
+	case len(prev) == 1 && len(next) == 1 && strings.Contains(operatorBytes, prev) && strings.Contains(operatorBytes, next):
+		// multi-character operators, e.g. <>, >=, ||
+		return false
+	}
+	return true
+}
+
+// operatorBytes are the characters of the multi-character operators, tokenized one by one
+const operatorBytes = "<>=!|&"
+
+// sqlInfo holds the metadata parsed from a SQL statement
+type sqlInfo struct {
+	operation string
+	tables    []string
+}
+
+// parseSQL extracts the operation and the referenced tables of a SQL statement, the primary table comes first
+func parseSQL(sql string) sqlInfo {
+	return parseTokens(tokenizeSQL(sql))
+}
+
+func parseTokens(tokens []sqlToken) sqlInfo {
+	var info sqlInfo
+	p := sqlParser{tokens: tokens, ctes: map[string]bool{}}
+	info.operation = p.operation()
+	info.tables = p.tables(info.operation)
+	return info
+}
+
+type sqlParser struct {
+	tokens []sqlToken
+	ctes   map[string]bool
+}
+
+// operation classifies the statement by its leading keyword, skipping the leading parentheses and CTE definitions
+func (p *sqlParser) operation() string {
+	i := 0
+	for i < len(p.tokens) && p.tokens[i].isPunct("(") {
+		i++
+	}
+	if i == len(p.tokens) {
+		return ""
+	}
+
+	first := p.tokens[i]
+	if first.is("WITH") {
+		return p.cteOperation(i + 1)
+	}
+	if first.kind != tokWord {
+		return OpRaw
+	}
+
+	switch strings.ToUpper(first.text) {
+	case "SELECT", "VALUES":
+		return OpSelect
+	case "INSERT", "REPLACE", "UPSERT":
+		return OpInsert
+	case "UPDATE":
+		return OpUpdate
+	case "DELETE":
+		return OpDelete
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "COMMENT":
+		return OpDDL
+	case "CALL", "EXEC", "EXECUTE":
+		return OpExec
+	default:
+		return OpRaw
+	}
+}
+
+// cteOperation records the CTE names and returns the operation of the main statement after them
+func (p *sqlParser) cteOperation(i int) string {
+	depth := 0
+	expectName := true
+	for ; i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+		case depth > 0:
+		case t.isPunct(","):
+			expectName = true
+		case t.is("RECURSIVE"):
+		case expectName && (t.kind == tokWord || t.kind == tokQuotedIdent):
+			p.ctes[strings.ToLower(unquoteIdent(t.text))] = true
+			expectName = false
+		case t.is("SELECT"):
+			return OpSelect
+		case t.is("INSERT"):
+			return OpInsert
+		case t.is("UPDATE"):
+			return OpUpdate
+		case t.is("DELETE"):
+			return OpDelete
+		}
+	}
+	return OpRaw
+}
+
+// tables collects the table references in order of appearance, without duplicates
+func (p *sqlParser) tables(op string) []string {
+	var tables []string
+	add := func(name string) {
+		if name == "" || p.ctes[strings.ToLower(name)] {
+			return
+		}
+		for _, t := range tables {
+			if t == name {
+				return
+			}
+		}
+		tables = append(tables, name)
+	}
+
+	// queryGroups tracks whether each open parenthesis is a (sub)query, where FROM introduces tables,
+	// as opposed to an expression, e.g. EXTRACT(YEAR FROM created_at)
+	queryGroups := []bool{true}
+	for i := 0; i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		switch {
+		case t.isPunct("("):
+			queryGroups = append(queryGroups, i+1 < len(p.tokens) && (p.tokens[i+1].is("SELECT") || p.tokens[i+1].is("WITH")))
+		case t.isPunct(")"):
+			if len(queryGroups) > 1 {
+				queryGroups = queryGroups[:len(queryGroups)-1]
+			}
+		case !queryGroups[len(queryGroups)-1]:
+		case t.is("FROM") || t.is("USING"):
+			for {
+				name, next := p.sourceRef(i + 1)
+				add(name)
+				next = p.skipAlias(next)
+				if next >= len(p.tokens) || !p.tokens[next].isPunct(",") {
+					break
+				}
+				i = next
+			}
+		case t.is("JOIN"):
+			name, _ := p.sourceRef(i + 1)
+			add(name)
+		case t.is("INTO"):
+			name, _ := p.tableRef(i + 1)
+			add(name)
+		case t.is("UPDATE") && op == OpUpdate:
+			name, _ := p.tableRef(i + 1)
+			add(name)
+		case t.is("TABLE") && op == OpDDL:
+			name, _ := p.tableRef(p.skipKeywords(i+1, "IF", "NOT", "EXISTS"))
+			add(name)
+		case t.is("ON") && op == OpDDL && p.afterIndexName(i):
+			// CREATE INDEX idx ON table, as opposed to ON DELETE of a foreign key
+			name, _ := p.tableRef(i + 1)
+			add(name)
+		}
+	}
+	return tables
+}
+
+// afterIndexName reports whether position i follows CREATE [UNIQUE] INDEX [CONCURRENTLY] [IF NOT EXISTS] [name]
+func (p *sqlParser) afterIndexName(i int) bool {
+	j := i - 1
+	// the optional, possibly qualified, index name
+	for j >= 0 && (p.tokens[j].kind == tokQuotedIdent || (p.tokens[j].kind == tokWord && !isReservedWord(p.tokens[j].text) && !p.tokens[j].is("INDEX"))) {
+		j--
+		if j < 0 || !p.tokens[j].isPunct(".") {
+			break
+		}
+		j--
+	}
+	for j >= 0 && (p.tokens[j].is("EXISTS") || p.tokens[j].is("NOT") || p.tokens[j].is("IF") || p.tokens[j].is("CONCURRENTLY")) {
+		j--
+	}
+	return j >= 0 && p.tokens[j].is("INDEX")
+}
+
+// tableRef reads a possibly qualified table name at position i, returning the name and the position after it.
+// Subqueries return an empty name.
+func (p *sqlParser) tableRef(i int) (string, int) {
+	i = p.skipKeywords(i, "ONLY", "LATERAL")
+	var parts []string
+	for i < len(p.tokens) {
+		t := p.tokens[i]
+		if (t.kind != tokWord || isReservedWord(t.text)) && t.kind != tokQuotedIdent {
+			break
+		}
+		parts = append(parts, unquoteIdent(t.text))
+		i++
+		if i+1 < len(p.tokens) && p.tokens[i].isPunct(".") {
+			i++
+			continue
+		}
+		break
+	}
+	return strings.Join(parts, "."), i
+}
+
+// sourceRef reads a table reference of a FROM or JOIN clause at position i, see tableRef.
+// Table-valued functions return an empty name.
+func (p *sqlParser) sourceRef(i int) (string, int) {
+	name, next := p.tableRef(i)
+	if next < len(p.tokens) && p.tokens[next].isPunct("(") {
+		return "", next
+	}
+	return name, next
+}
+
+// skipAlias skips an optional table alias at position i
+func (p *sqlParser) skipAlias(i int) int {
+	if i < len(p.tokens) && p.tokens[i].is("AS") {
+		i++
+	}
+	if i < len(p.tokens) && (p.tokens[i].kind == tokQuotedIdent || (p.tokens[i].kind == tokWord && !isReservedWord(p.tokens[i].text))) {
+		i++
+	}
+	return i
+}
+
+// skipKeywords skips any of the given keywords at position i
+func (p *sqlParser) skipKeywords(i int, keywords ...string) int {
+	for i < len(p.tokens) {
+		matched := false
+		for _, k := range keywords {
+			if p.tokens[i].is(k) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+// reservedWords are the keywords which can never be a table name or alias
+var reservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"FULL": true, "OUTER": true, "CROSS": true, "NATURAL": true, "ON": true, "USING": true, "GROUP": true,
+	"ORDER": true, "BY": true, "HAVING": true, "LIMIT": true, "OFFSET": true, "UNION": true, "EXCEPT": true,
+	"INTERSECT": true, "SET": true, "VALUES": true, "RETURNING": true, "AS": true, "WINDOW": true, "FOR": true,
+	"INTO": true, "AND": true, "OR": true, "NOT": true, "DEFAULT": true, "FETCH": true, "LOCK": true,
+	"UPDATE": true, "DELETE": true,
+}
+
+// parenKeywords are the keywords, besides the reserved words, which can be followed by a parenthesis
+var parenKeywords = map[string]bool{
+	"IN": true, "EXISTS": true, "ANY": true, "ALL": true, "SOME": true, "IS": true, "THEN": true, "ELSE": true,
+	"WHEN": true, "CASE": true, "TABLE": true, "RECURSIVE": true, "LATERAL": true, "BETWEEN": true, "LIKE": true,
+}
+
+func isReservedWord(s string) bool {
+	return reservedWords[strings.ToUpper(s)]
+}
+
+// unquoteIdent removes the quotes of a quoted identifier
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '`') && s[len(s)-1] == s[0] {
+		q := string(s[0])
+		return strings.ReplaceAll(s[1:len(s)-1], q+q, q)
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= utf8.RuneSelf
+}
+
+// wordEnd returns the length of the identifier at the start of s
+func wordEnd(s string) int {
+	i := 0
+	for i < len(s) {
+		if s[i] < utf8.RuneSelf {
+			if !isWordByte(s[i]) && !isDigit(s[i]) && s[i] != '$' {
+				break
+			}
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i += n
+	}
+	if i == 0 {
+		// invalid UTF-8, consume one byte to always make progress
+		return 1
+	}
+	return i
+}
+
+// numberEnd returns the length of the numeric literal at the start of s
+func numberEnd(s string) int {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return 2 + spanOf(s[2:], func(c byte) bool { return isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'f') })
+	}
+	i := spanOf(s, isDigit)
+	if i < len(s) && s[i] == '.' {
+		i += 1 + spanOf(s[i+1:], isDigit)
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if n := spanOf(s[j:], isDigit); n > 0 {
+			i = j + n
+		}
+	}
+	return i
+}
+
+// quotedEnd returns the length of the quoted text at the start of s, doubled quotes are escaped quotes
+func quotedEnd(s string, q byte) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] == q {
+			if i+1 < len(s) && s[i+1] == q {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// dollarQuotedEnd returns the length of the PostgreSQL dollar-quoted string at the start of s, or 0 if it's not one
+func dollarQuotedEnd(s string) int {
+	tagEnd := strings.IndexByte(s[1:], '$')
+	if tagEnd < 0 {
+		return 0
+	}
+	tag := s[:tagEnd+2]
+	for _, c := range []byte(tag[1 : len(tag)-1]) {
+		if !isWordByte(c) && !isDigit(c) {
+			return 0
+		}
+	}
+	if end := strings.Index(s[len(tag):], tag); end >= 0 {
+		return len(tag) + end + len(tag)
+	}
+	return len(s)
+}
+
+func lineEnd(s string) int {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return i + 1
+	}
+	return len(s)
+}
+
+func spanOf(s string, fn func(byte) bool) int {
+	i := 0
+	for i < len(s) && fn(s[i]) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,152 @@
+package sloggorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_tokenizeSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []sqlToken
+	}{
+		{
+			name: "empty",
+			sql:  "",
+			want: []sqlToken{},
+		},
+		{
+			name: "literals and placeholders",
+			sql:  "SELECT * FROM `users` WHERE name = 'it''s' AND age > 1.5e3 AND id IN ($1, ?, @p) -- comment",
+			want: []sqlToken{
+				{tokWord, "SELECT"}, {tokPunct, "*"}, {tokWord, "FROM"}, {tokQuotedIdent, "`users`"}, {tokWord, "WHERE"},
+				{tokWord, "name"}, {tokPunct, "="}, {tokString, "'it''s'"}, {tokWord, "AND"}, {tokWord, "age"},
+				{tokPunct, ">"}, {tokNumber, "1.5e3"}, {tokWord, "AND"}, {tokWord, "id"}, {tokWord, "IN"}, {tokPunct, "("},
+				{tokPlaceholder, "$1"}, {tokPunct, ","}, {tokPlaceholder, "?"}, {tokPunct, ","}, {tokPlaceholder, "@p"},
+				{tokPunct, ")"},
+			},
+		},
+		{
+			name: "comments and dollar quotes",
+			sql:  "/* hint */ SELECT $tag$ a 'b' $tag$, 0x1F",
+			want: []sqlToken{
+				{tokWord, "SELECT"}, {tokString, "$tag$ a 'b' $tag$"}, {tokPunct, ","}, {tokNumber, "0x1F"},
+			},
+		},
+		{
+			name: "unterminated",
+			sql:  `SELECT "unterminated /* `,
+			want: []sqlToken{
+				{tokWord, "SELECT"}, {tokQuotedIdent, `"unterminated /* `},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tokenizeSQL(tt.sql))
+		})
+	}
+}
+
+func Test_parseSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want sqlInfo
+	}{
+		{"", sqlInfo{}},
+		{"SELECT 1", sqlInfo{operation: OpSelect}},
+		{
+			"SELECT * FROM `users` WHERE `users`.`id` = 1 ORDER BY `users`.`id` LIMIT 1",
+			sqlInfo{OpSelect, []string{"users"}},
+		},
+		{
+			`SELECT u.* FROM "public"."users" AS u LEFT JOIN "orders" o ON o.user_id = u.id INNER JOIN profiles ON true`,
+			sqlInfo{OpSelect, []string{"public.users", "orders", "profiles"}},
+		},
+		{
+			"SELECT * FROM users u, orders o WHERE EXTRACT(YEAR FROM o.created_at) = 2024",
+			sqlInfo{OpSelect, []string{"users", "orders"}},
+		},
+		{
+			"SELECT * FROM (SELECT * FROM logs) AS l WHERE id IN (SELECT log_id FROM archived)",
+			sqlInfo{OpSelect, []string{"logs", "archived"}},
+		},
+		{
+			"WITH recent AS (SELECT * FROM orders WHERE created_at > ?) DELETE FROM carts WHERE id IN (SELECT cart_id FROM recent)",
+			sqlInfo{OpDelete, []string{"orders", "carts"}},
+		},
+		{
+			"INSERT INTO `users` (`name`,`age`) VALUES ('jinzhu',18) ON DUPLICATE KEY UPDATE `age`=VALUES(`age`)",
+			sqlInfo{OpInsert, []string{"users"}},
+		},
+		{
+			`UPDATE "users" SET "age"=18 WHERE "id" = 1`,
+			sqlInfo{OpUpdate, []string{"users"}},
+		},
+		{
+			"DELETE FROM users WHERE id = 1",
+			sqlInfo{OpDelete, []string{"users"}},
+		},
+		{
+			"CREATE TABLE IF NOT EXISTS `users` (`id` integer, `company_id` integer REFERENCES companies ON DELETE CASCADE)",
+			sqlInfo{OpDDL, []string{"users"}},
+		},
+		{
+			"CREATE UNIQUE INDEX `idx_users_name` ON `users`(`name`)",
+			sqlInfo{OpDDL, []string{"users"}},
+		},
+		{
+			"ALTER TABLE `users` ADD CONSTRAINT `fk_users_company` FOREIGN KEY (`company_id`) REFERENCES `companies`(`id`) ON DELETE CASCADE ON UPDATE CASCADE",
+			sqlInfo{OpDDL, []string{"users"}},
+		},
+		{
+			"CREATE INDEX IF NOT EXISTS public.idx_users_age ON public.users (age)",
+			sqlInfo{OpDDL, []string{"public.users"}},
+		},
+		{`CREATE INDEX CONCURRENTLY ON "users" ("age")`, sqlInfo{OpDDL, []string{"users"}}},
+		{"CALL refresh_stats(?)", sqlInfo{operation: OpExec}},
+		{"SAVEPOINT sp1", sqlInfo{operation: OpRaw}},
+		{"(SELECT a FROM t1) UNION (SELECT a FROM t2)", sqlInfo{OpSelect, []string{"t1", "t2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseSQL(tt.sql))
+		})
+	}
+}
+
+func Test_fingerprint(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"", ""},
+		{
+			"SELECT * FROM `users` WHERE `users`.`id` = 1 ORDER BY `users`.`id` LIMIT 1",
+			"SELECT * FROM `users` WHERE `users`.`id` = ? ORDER BY `users`.`id` LIMIT ?",
+		},
+		{
+			"/* app:users */ SELECT  count(*)\n FROM users WHERE name = 'jinzhu' AND id IN (1, 2, 3) -- trailing",
+			"SELECT count(*) FROM users WHERE name = ? AND id IN (?)",
+		},
+		{
+			`INSERT INTO "users" ("name","age") VALUES ($1,$2),($3,$4),($5,$6) RETURNING "id"`,
+			`INSERT INTO "users" ("name", "age") VALUES (?) RETURNING "id"`,
+		},
+		{
+			"SELECT * FROM users WHERE a <> 1 AND b >= 2 AND c::text = 'x' OR d || e != f",
+			"SELECT * FROM users WHERE a <> ? AND b >= ? AND c::text = ? OR d || e != f",
+		},
+		{
+			"SELECT * FROM users WHERE (a = 1) AND (b = 2)",
+			"SELECT * FROM users WHERE (a = ?) AND (b = ?)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.want, fingerprint(tt.sql))
+		})
+	}
+}
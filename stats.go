@@ -0,0 +1,164 @@
+package sloggorm
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxStatsFingerprints is the max number of fingerprints aggregated, the statements of the following ones are counted as dropped
+const maxStatsFingerprints = 1000
+
+// StatsBuckets are the upper bounds of the latency histogram buckets, see QueryStats.Histogram
+var StatsBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// StatsSnapshot is the statistics of the statements traced since Since, see WithStats
+type StatsSnapshot struct {
+	Since   time.Time    `json:"since"`
+	Time    time.Time    `json:"time"`
+	Queries []QueryStats `json:"queries"` // by total duration, descending
+	Dropped uint64       `json:"dropped"` // the statements not aggregated, once the max number of fingerprints is reached
+}
+
+// QueryStats is the statistics of the statements sharing a fingerprint
+type QueryStats struct {
+	Fingerprint   string        `json:"fingerprint"`
+	Operation     string        `json:"operation,omitempty"`
+	Count         uint64        `json:"count"`
+	Errors        uint64        `json:"errors"`
+	Rows          int64         `json:"rows"`
+	TotalDuration time.Duration `json:"total_duration"` // in nanoseconds
+	MinDuration   time.Duration `json:"min_duration"`   // in nanoseconds
+	MaxDuration   time.Duration `json:"max_duration"`   // in nanoseconds
+	// Histogram counts the statements by duration, one per bucket of StatsBuckets, plus one for the larger durations
+	Histogram []uint64 `json:"histogram"`
+}
+
+// Percentile returns the approximate duration below which the given percentage of the statements fall, e.g. 95,
+// i.e. the upper bound of the histogram bucket, or MaxDuration for the last one
+func (s QueryStats) Percentile(p float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := uint64(float64(s.Count)*p/100 + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range s.Histogram {
+		seen += n
+		if seen >= rank {
+			if i < len(StatsBuckets) && StatsBuckets[i] < s.MaxDuration {
+				return StatsBuckets[i]
+			}
+			return s.MaxDuration
+		}
+	}
+	return s.MaxDuration
+}
+
+// queryStats aggregates the traced statements by fingerprint
+type queryStats struct {
+	mu      sync.Mutex
+	since   time.Time
+	queries map[string]*QueryStats
+	dropped uint64
+}
+
+func newQueryStats() *queryStats {
+	return &queryStats{since: time.Now(), queries: map[string]*QueryStats{}}
+}
+
+// add aggregates a statement, it returns false if dropped
+func (s *queryStats) add(fingerprint, operation string, elapsed time.Duration, rows int64, failed bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queries[fingerprint]
+	if q == nil {
+		if len(s.queries) >= maxStatsFingerprints {
+			s.dropped++
+			return false
+		}
+		q = &QueryStats{
+			Fingerprint: fingerprint,
+			Operation:   operation,
+			MinDuration: elapsed,
+			Histogram:   make([]uint64, len(StatsBuckets)+1),
+		}
+		s.queries[fingerprint] = q
+	}
+
+	q.Count++
+	if failed {
+		q.Errors++
+	}
+	if rows > 0 {
+		q.Rows += rows
+	}
+	q.TotalDuration += elapsed
+	q.MinDuration = min(q.MinDuration, elapsed)
+	q.MaxDuration = max(q.MaxDuration, elapsed)
+	q.Histogram[sort.Search(len(StatsBuckets), func(i int) bool { return elapsed <= StatsBuckets[i] })]++
+	return true
+}
+
+// snapshot returns a copy of the statistics, and resets them if reset is set
+func (s *queryStats) snapshot(reset bool) StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := StatsSnapshot{
+		Since:   s.since,
+		Time:    time.Now(),
+		Queries: make([]QueryStats, 0, len(s.queries)),
+		Dropped: s.dropped,
+	}
+	for _, q := range s.queries {
+		c := *q
+		c.Histogram = append([]uint64(nil), q.Histogram...)
+		snapshot.Queries = append(snapshot.Queries, c)
+	}
+	sort.Slice(snapshot.Queries, func(i, j int) bool {
+		a, b := snapshot.Queries[i], snapshot.Queries[j]
+		if a.TotalDuration != b.TotalDuration {
+			return a.TotalDuration > b.TotalDuration
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+
+	if reset {
+		s.since = snapshot.Time
+		s.queries = map[string]*QueryStats{}
+		s.dropped = 0
+	}
+	return snapshot
+}
+
+// Stats returns the statistics of the statements traced since the logger creation or the last reset, see WithStats
+func (l *logger) Stats() StatsSnapshot {
+	if l.stats == nil {
+		return StatsSnapshot{Queries: []QueryStats{}}
+	}
+	return l.stats.snapshot(false)
+}
+
+// ResetStats returns the statistics like Stats, and resets them
+func (l *logger) ResetStats() StatsSnapshot {
+	if l.stats == nil {
+		return StatsSnapshot{Queries: []QueryStats{}}
+	}
+	return l.stats.snapshot(true)
+}
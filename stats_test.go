@@ -0,0 +1,102 @@
+package sloggorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithStats(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithStats(true))
+	db := openTestDB(t, NewPlugin(l))
+	l.ResetStats()
+
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, db.Create(&testCompany{Name: name}).Error)
+	}
+	var companies []testCompany
+	require.NoError(t, db.Where("name <> ?", "a").Find(&companies).Error)
+	assert.Error(t, db.Exec("SELECT * FROM missing").Error)
+
+	stats := l.Stats()
+	require.Len(t, stats.Queries, 3)
+	byFingerprint := map[string]QueryStats{}
+	for _, q := range stats.Queries {
+		byFingerprint[q.Fingerprint] = q
+	}
+
+	insert := byFingerprint["INSERT INTO `test_companies` (`name`) VALUES (?) RETURNING `id`"]
+	assert.Equal(t, OpInsert, insert.Operation)
+	assert.Equal(t, uint64(3), insert.Count)
+	assert.Equal(t, uint64(0), insert.Errors)
+	assert.Equal(t, int64(3), insert.Rows)
+	assert.LessOrEqual(t, insert.MinDuration, insert.MaxDuration)
+	assert.GreaterOrEqual(t, insert.TotalDuration, insert.MaxDuration)
+	assert.Len(t, insert.Histogram, len(StatsBuckets)+1)
+	var total uint64
+	for _, n := range insert.Histogram {
+		total += n
+	}
+	assert.Equal(t, insert.Count, total)
+
+	query := byFingerprint["SELECT * FROM `test_companies` WHERE name <> ?"]
+	assert.Equal(t, OpSelect, query.Operation)
+	assert.Equal(t, int64(2), query.Rows)
+
+	failed := byFingerprint["SELECT * FROM missing"]
+	assert.Equal(t, uint64(1), failed.Errors)
+
+	assert.GreaterOrEqual(t, stats.Queries[0].TotalDuration, stats.Queries[1].TotalDuration)
+	assert.False(t, stats.Since.After(stats.Time))
+
+	t.Run("reset", func(t *testing.T) {
+		reset := l.ResetStats()
+		assert.Len(t, reset.Queries, 3)
+		after := l.Stats()
+		assert.Empty(t, after.Queries)
+		assert.Equal(t, reset.Time, after.Since)
+	})
+
+	t.Run("shared by derived loggers", func(t *testing.T) {
+		require.NoError(t, db.Debug().Find(&companies).Error)
+		assert.Len(t, l.Stats().Queries, 1)
+		l.ResetStats()
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		l := NewWithConfig(NewConfig(buf.handler()))
+		l.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+		assert.Empty(t, l.Stats().Queries)
+		assert.Empty(t, l.ResetStats().Queries)
+	})
+}
+
+func Test_queryStats(t *testing.T) {
+	s := newQueryStats()
+	for _, d := range []time.Duration{time.Millisecond / 2, 3 * time.Millisecond, 3 * time.Millisecond, 20 * time.Second} {
+		s.add("SELECT ?", OpSelect, d, -1, false)
+	}
+	q := s.snapshot(false).Queries[0]
+	assert.Equal(t, int64(0), q.Rows)
+	assert.Equal(t, time.Millisecond/2, q.MinDuration)
+	assert.Equal(t, 20*time.Second, q.MaxDuration)
+	assert.Equal(t, []uint64{1, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, q.Histogram)
+	assert.Equal(t, 5*time.Millisecond, q.Percentile(50))
+	assert.Equal(t, 20*time.Second, q.Percentile(95))
+	assert.Equal(t, time.Duration(0), QueryStats{}.Percentile(95))
+
+	t.Run("max fingerprints", func(t *testing.T) {
+		s := newQueryStats()
+		for i := 0; i <= maxStatsFingerprints; i++ {
+			s.add(string(rune('a'+i%26))+string(rune(i)), OpSelect, time.Millisecond, 1, false)
+		}
+		snapshot := s.snapshot(true)
+		assert.Len(t, snapshot.Queries, maxStatsFingerprints)
+		assert.Equal(t, uint64(1), snapshot.Dropped)
+		assert.Equal(t, uint64(0), s.snapshot(false).Dropped)
+	})
+}
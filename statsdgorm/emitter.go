@@ -0,0 +1,233 @@
+// Package statsdgorm sends the statements traced by sloggorm as StatsD metrics, with the DogStatsD tags, over UDP.
+//
+//	emitter := statsdgorm.New("127.0.0.1:8125").WithTags("env:prod")
+//	err := emitter.Start()
+//	defer emitter.Close() // flushes the buffered metrics
+//	glogger := sloggorm.NewWithConfig(sloggorm.NewConfig(handler).WithObservers(emitter))
+package statsdgorm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+// OtherTag replaces the tag values beyond the cardinality limits
+const OtherTag = "other"
+
+// New creates an emitter sending the metrics of the statements traced by the loggers it observes to the given UDP address, see sloggorm.WithObservers.
+//
+// Per statement, it sends the query.duration timing and the query.count counter tagged by operation, table and outcome,
+// the query.errors counter tagged by operation, table and error_class, and the query.rows counter tagged by operation and table.
+// The metrics are batched in packets, sent when full or every flush interval.
+func New(addr string) *Emitter {
+	return &Emitter{
+		addr:          addr,
+		prefix:        "gorm.",
+		flushInterval: time.Second,
+		maxPacketSize: 1432,
+		maxTables:     100,
+	}
+}
+
+// Emitter is a sloggorm.Observer sending StatsD metrics, see New
+type Emitter struct {
+	addr          string
+	prefix        string
+	tags          []string
+	flushInterval time.Duration
+	maxPacketSize int
+	maxTables     int
+
+	mu     sync.Mutex
+	conn   net.Conn
+	buf    bytes.Buffer
+	tables map[string]bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+	startErr  error
+}
+
+// WithPrefix set the prefix of the metric names. Default "gorm."
+func (e *Emitter) WithPrefix(v string) *Emitter {
+	e.prefix = v
+	return e
+}
+
+// WithTags set the tags added to all the metrics, e.g. "env:prod". Default none
+func (e *Emitter) WithTags(v ...string) *Emitter {
+	e.tags = v
+	return e
+}
+
+// WithFlushInterval set the maximum delay of the buffered metrics. Default 1s
+func (e *Emitter) WithFlushInterval(v time.Duration) *Emitter {
+	e.flushInterval = v
+	return e
+}
+
+// WithMaxPacketSize set the maximum size of the UDP packets, in bytes. Default 1432, to fit the Ethernet MTU
+func (e *Emitter) WithMaxPacketSize(v int) *Emitter {
+	e.maxPacketSize = v
+	return e
+}
+
+// WithMaxTables set the number of distinct table tag values, the next tables are reported as OtherTag. Default 100
+func (e *Emitter) WithMaxTables(v int) *Emitter {
+	e.maxTables = v
+	return e
+}
+
+// Start connects to the address and starts the flush goroutine, which must be stopped with Close.
+// The statements traced before Start are ignored.
+func (e *Emitter) Start() error {
+	if e.flushInterval <= 0 {
+		return errors.New("statsdgorm: the flush interval must be positive")
+	}
+
+	e.startOnce.Do(func() {
+		conn, err := net.Dial("udp", e.addr)
+		if err != nil {
+			e.startErr = err
+			return
+		}
+		e.mu.Lock()
+		e.conn = conn
+		e.tables = map[string]bool{}
+		e.mu.Unlock()
+
+		e.stop = make(chan struct{})
+		e.done = make(chan struct{})
+		ticker := time.NewTicker(e.flushInterval)
+		go func() {
+			defer close(e.done)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-e.stop:
+					return
+				case <-ticker.C:
+					e.Flush()
+				}
+			}
+		}()
+	})
+	return e.startErr
+}
+
+// Close stops the flush goroutine, then flushes the buffered metrics and closes the connection
+func (e *Emitter) Close() error {
+	if e.stop == nil {
+		return nil
+	}
+	var err error
+	e.closeOnce.Do(func() {
+		close(e.stop)
+		<-e.done
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		err = errors.Join(e.flush(), e.conn.Close())
+		e.conn = nil
+	})
+	return err
+}
+
+// Flush sends the buffered metrics
+func (e *Emitter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.flush()
+}
+
+// flush sends the buffered metrics, the lock must be held
+func (e *Emitter) flush() error {
+	if e.buf.Len() == 0 || e.conn == nil {
+		return nil
+	}
+	_, err := e.conn.Write(e.buf.Bytes())
+	e.buf.Reset()
+	return err
+}
+
+// ObserveTrace implements sloggorm.Observer
+func (e *Emitter) ObserveTrace(_ context.Context, ev sloggorm.TraceEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return
+	}
+
+	op := strings.ToLower(ev.Operation)
+	if op == "" {
+		op = OtherTag
+	}
+	tags := append(make([]string, 0, len(e.tags)+3), e.tags...)
+	tags = append(tags, "operation:"+op)
+	if table := e.table(ev.Table()); table != "" {
+		tags = append(tags, "table:"+table)
+	}
+
+	ms := strconv.FormatFloat(float64(ev.Duration)/float64(time.Millisecond), 'f', -1, 64)
+	e.write("query.duration", ms, "ms", append(tags, "outcome:"+ev.Outcome))
+	e.write("query.count", "1", "c", append(tags, "outcome:"+ev.Outcome))
+	if ev.Outcome == sloggorm.OutcomeError {
+		e.write("query.errors", "1", "c", append(tags, "error_class:"+sloggorm.ErrorClass(ev.Err)))
+	}
+	if ev.Rows > 0 {
+		e.write("query.rows", strconv.FormatInt(ev.Rows, 10), "c", tags)
+	}
+}
+
+// write buffers a metric line, flushing the buffer first if the packet would be too large; the lock must be held
+func (e *Emitter) write(name, value, typ string, tags []string) {
+	var line bytes.Buffer
+	line.WriteString(e.prefix)
+	line.WriteString(name)
+	line.WriteByte(':')
+	line.WriteString(value)
+	line.WriteByte('|')
+	line.WriteString(typ)
+	for i, tag := range tags {
+		if i == 0 {
+			line.WriteString("|#")
+		} else {
+			line.WriteByte(',')
+		}
+		line.WriteString(tag)
+	}
+
+	if e.buf.Len() > 0 && e.buf.Len()+1+line.Len() > e.maxPacketSize {
+		_ = e.flush()
+	}
+	if e.buf.Len() > 0 {
+		e.buf.WriteByte('\n')
+	}
+	e.buf.Write(line.Bytes())
+}
+
+// table returns the table tag value, OtherTag once the limit of distinct tables is reached; the lock must be held
+func (e *Emitter) table(name string) string {
+	name = tagReplacer.Replace(name)
+	if name == "" || e.tables[name] {
+		return name
+	}
+	if len(e.tables) >= e.maxTables {
+		return OtherTag
+	}
+	e.tables[name] = true
+	return name
+}
+
+// tagReplacer replaces the characters of the StatsD protocol in the tag values
+var tagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", ":", "_", "\n", "_", " ", "_")
@@ -0,0 +1,133 @@
+package statsdgorm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	sloggorm "github.com/imdatngo/slog-gorm/v2"
+)
+
+type testCompany struct {
+	ID   uint
+	Name string
+}
+
+// listen starts a local UDP listener
+func listen(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// packets reads the packets received within the timeout
+func packets(t *testing.T, conn net.PacketConn, timeout time.Duration) []string {
+	t.Helper()
+	var got []string
+	buf := make([]byte, 65536)
+	for {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(timeout)))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			require.True(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+			return got
+		}
+		got = append(got, string(buf[:n]))
+	}
+}
+
+func TestEmitter(t *testing.T) {
+	conn := listen(t)
+	e := New(conn.LocalAddr().String()).WithTags("env:test").WithFlushInterval(time.Hour)
+	require.NoError(t, e.Start())
+	require.NoError(t, e.Start())
+
+	l := sloggorm.NewWithConfig(sloggorm.NewConfig(slog.NewTextHandler(&strings.Builder{}, nil)).WithObservers(e))
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: l})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(sloggorm.NewPlugin(l)))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	require.NoError(t, db.AutoMigrate(&testCompany{}))
+	require.NoError(t, e.Flush())
+	packets(t, conn, 100*time.Millisecond)
+
+	require.NoError(t, db.Create(&testCompany{Name: "a"}).Error)
+	assert.Error(t, db.Exec("SELECT * FROM missing").Error)
+	assert.Empty(t, packets(t, conn, 50*time.Millisecond), "buffered until the flush")
+
+	require.NoError(t, e.Close())
+	require.NoError(t, e.Close())
+	got := packets(t, conn, time.Second)
+	require.Len(t, got, 1)
+	lines := strings.Split(got[0], "\n")
+	require.Len(t, lines, 6)
+
+	assert.Regexp(t, `^gorm\.query\.duration:[0-9.]+\|ms\|#env:test,operation:insert,table:test_companies,outcome:ok$`, lines[0])
+	assert.Equal(t, "gorm.query.count:1|c|#env:test,operation:insert,table:test_companies,outcome:ok", lines[1])
+	assert.Equal(t, "gorm.query.rows:1|c|#env:test,operation:insert,table:test_companies", lines[2])
+	assert.Regexp(t, `^gorm\.query\.duration:[0-9.]+\|ms\|#env:test,operation:select,table:missing,outcome:error$`, lines[3])
+	assert.Equal(t, "gorm.query.count:1|c|#env:test,operation:select,table:missing,outcome:error", lines[4])
+	assert.Equal(t, "gorm.query.errors:1|c|#env:test,operation:select,table:missing,error_class:other", lines[5])
+
+	t.Run("ignored once closed", func(t *testing.T) {
+		e.ObserveTrace(context.Background(), sloggorm.TraceEvent{Outcome: sloggorm.OutcomeOK})
+		assert.NoError(t, e.Flush())
+		assert.Empty(t, packets(t, conn, 50*time.Millisecond))
+	})
+}
+
+func TestEmitter_batching(t *testing.T) {
+	ctx := context.Background()
+	conn := listen(t)
+	e := New(conn.LocalAddr().String()).WithPrefix("").WithMaxPacketSize(130).WithMaxTables(1).WithFlushInterval(time.Hour)
+
+	e.ObserveTrace(ctx, sloggorm.TraceEvent{Outcome: sloggorm.OutcomeOK}) // not started
+	require.NoError(t, e.Start())
+
+	e.ObserveTrace(ctx, sloggorm.TraceEvent{Operation: sloggorm.OpSelect, Tables: []string{"a,b"}, Duration: 1500 * time.Microsecond, Rows: -1, Outcome: sloggorm.OutcomeSlow})
+	e.ObserveTrace(ctx, sloggorm.TraceEvent{Operation: sloggorm.OpSelect, Tables: []string{"c"}, Duration: time.Millisecond, Outcome: sloggorm.OutcomeOK})
+	require.NoError(t, e.Close())
+
+	got := packets(t, conn, 200*time.Millisecond)
+	require.Len(t, got, 2)
+	for _, p := range got {
+		assert.LessOrEqual(t, len(p), 130)
+	}
+	assert.Equal(t, "query.duration:1.5|ms|#operation:select,table:a_b,outcome:slow\nquery.count:1|c|#operation:select,table:a_b,outcome:slow", got[0])
+	assert.Equal(t, "query.duration:1|ms|#operation:select,table:other,outcome:ok\nquery.count:1|c|#operation:select,table:other,outcome:ok", got[1])
+}
+
+func TestEmitter_flushInterval(t *testing.T) {
+	conn := listen(t)
+	e := New(conn.LocalAddr().String()).WithFlushInterval(10 * time.Millisecond)
+	require.NoError(t, e.Start())
+	defer e.Close()
+
+	e.ObserveTrace(context.Background(), sloggorm.TraceEvent{Operation: sloggorm.OpDelete, Outcome: sloggorm.OutcomeOK})
+	got := packets(t, conn, 200*time.Millisecond)
+	require.Len(t, got, 1)
+	assert.Equal(t, "gorm.query.duration:0|ms|#operation:delete,outcome:ok\ngorm.query.count:1|c|#operation:delete,outcome:ok", got[0])
+}
+
+func TestEmitter_Start(t *testing.T) {
+	assert.Error(t, New("127.0.0.1:8125").WithFlushInterval(0).Start())
+	assert.Error(t, New("not an address").Start())
+	assert.NoError(t, New("127.0.0.1:8125").Close())
+}
@@ -0,0 +1,142 @@
+package sloggorm
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// TraceContext is the identity of a trace span, as hex strings
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Flags   byte
+}
+
+// Sampled reports whether the sampled flag is set
+func (t TraceContext) Sampled() bool {
+	return t.Flags&0x01 != 0
+}
+
+// NewTraceExtractor creates a context extractor adding the trace and span IDs found by the source function, see WithContextExtractor:
+//
+//	cfg.WithContextExtractor(sloggorm.NewTraceExtractor(sloggorm.TraceparentFrom(traceparentKey)).Extract)
+//
+// Nothing is added when the context lacks them, or the source panics.
+func NewTraceExtractor(source func(ctx context.Context) (TraceContext, bool)) *TraceExtractor {
+	return &TraceExtractor{
+		source:     source,
+		traceIDKey: "trace_id",
+		spanIDKey:  "span_id",
+	}
+}
+
+// TraceExtractor extracts the trace context as log attributes, see NewTraceExtractor
+type TraceExtractor struct {
+	source        func(ctx context.Context) (TraceContext, bool)
+	traceIDKey    string
+	spanIDKey     string
+	traceFlagsKey string
+}
+
+// WithTraceIDKey set the attribute key of the trace ID. Default "trace_id"
+func (e *TraceExtractor) WithTraceIDKey(v string) *TraceExtractor {
+	e.traceIDKey = v
+	return e
+}
+
+// WithSpanIDKey set the attribute key of the span ID, empty to omit it. Default "span_id"
+func (e *TraceExtractor) WithSpanIDKey(v string) *TraceExtractor {
+	e.spanIDKey = v
+	return e
+}
+
+// WithTraceFlagsKey set the attribute key of the trace flags, as 2 hex digits. Default is empty to omit them
+func (e *TraceExtractor) WithTraceFlagsKey(v string) *TraceExtractor {
+	e.traceFlagsKey = v
+	return e
+}
+
+// Extract returns the attributes of the trace context, to be used with WithContextExtractor
+func (e *TraceExtractor) Extract(ctx context.Context) (attrs []slog.Attr) {
+	if ctx == nil || e.source == nil {
+		return nil
+	}
+	defer func() {
+		if recover() != nil {
+			attrs = nil
+		}
+	}()
+
+	tc, ok := e.source(ctx)
+	if !ok || tc.TraceID == "" {
+		return nil
+	}
+	attrs = make([]slog.Attr, 0, 3)
+	if e.traceIDKey != "" {
+		attrs = append(attrs, slog.String(e.traceIDKey, tc.TraceID))
+	}
+	if e.spanIDKey != "" && tc.SpanID != "" {
+		attrs = append(attrs, slog.String(e.spanIDKey, tc.SpanID))
+	}
+	if e.traceFlagsKey != "" {
+		attrs = append(attrs, slog.String(e.traceFlagsKey, hex.EncodeToString([]byte{tc.Flags})))
+	}
+	return attrs
+}
+
+// TraceparentFrom returns a source of NewTraceExtractor parsing the W3C traceparent value of the given context key,
+// which can be a string, a []byte or a fmt.Stringer
+func TraceparentFrom(key any) func(ctx context.Context) (TraceContext, bool) {
+	return func(ctx context.Context) (TraceContext, bool) {
+		switch v := ctx.Value(key).(type) {
+		case string:
+			return ParseTraceparent(v)
+		case []byte:
+			return ParseTraceparent(string(v))
+		case fmt.Stringer:
+			return ParseTraceparent(v.String())
+		default:
+			return TraceContext{}, false
+		}
+	}
+}
+
+// ParseTraceparent parses a W3C traceparent header value: version-traceid-spanid-flags, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(s string) (TraceContext, bool) {
+	s = strings.TrimSpace(s)
+	// the future versions may append fields after the flags
+	if len(s) < 55 || (len(s) > 55 && s[55] != '-') {
+		return TraceContext{}, false
+	}
+	version, traceID, spanID, flags := s[0:2], s[3:35], s[36:52], s[53:55]
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return TraceContext{}, false
+	}
+	if !isLowerHex(version) || version == "ff" || (version == "00" && len(s) != 55) {
+		return TraceContext{}, false
+	}
+	if !isLowerHex(traceID) || isZeros(traceID) || !isLowerHex(spanID) || isZeros(spanID) || !isLowerHex(flags) {
+		return TraceContext{}, false
+	}
+	b, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Flags: b[0]}, true
+}
+
+// isLowerHex reports whether s only contains lowercase hex digits
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isZeros reports whether s only contains zeros, an invalid ID
+func isZeros(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
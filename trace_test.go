@@ -0,0 +1,123 @@
+package sloggorm
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   TraceContext
+		wantOk bool
+	}{
+		{"valid", testTraceparent, TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Flags: 1}, true},
+		{"spaces", " 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00 ", TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}, true},
+		{"future version", "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what", TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Flags: 1}, true},
+		{"empty", "", TraceContext{}, false},
+		{"short", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", TraceContext{}, false},
+		{"version 00 too long", testTraceparent + "-x", TraceContext{}, false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", TraceContext{}, false},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", TraceContext{}, false},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", TraceContext{}, false},
+		{"zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", TraceContext{}, false},
+		{"separators", "00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01", TraceContext{}, false},
+		{"invalid flags", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g", TraceContext{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseTraceparent(tt.value)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	tc, _ := ParseTraceparent(testTraceparent)
+	assert.True(t, tc.Sampled())
+}
+
+func TestTraceExtractor(t *testing.T) {
+	key := ctxKey("traceparent")
+	background := context.Background()
+
+	tests := []struct {
+		name      string
+		extractor *TraceExtractor
+		ctx       context.Context
+		want      []slog.Attr
+	}{
+		{
+			name:      "string",
+			extractor: NewTraceExtractor(TraceparentFrom(key)),
+			ctx:       context.WithValue(background, key, testTraceparent),
+			want:      []slog.Attr{slog.String("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"), slog.String("span_id", "00f067aa0ba902b7")},
+		},
+		{
+			name:      "bytes with keys and flags",
+			extractor: NewTraceExtractor(TraceparentFrom(key)).WithTraceIDKey("dd.trace_id").WithSpanIDKey("").WithTraceFlagsKey("trace_flags"),
+			ctx:       context.WithValue(background, key, []byte(testTraceparent)),
+			want:      []slog.Attr{slog.String("dd.trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"), slog.String("trace_flags", "01")},
+		},
+		{
+			name:      "stringer",
+			extractor: NewTraceExtractor(TraceparentFrom(key)).WithSpanIDKey("parent_id"),
+			ctx:       context.WithValue(background, key, stringer(testTraceparent)),
+			want:      []slog.Attr{slog.String("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"), slog.String("parent_id", "00f067aa0ba902b7")},
+		},
+		{
+			name:      "missing",
+			extractor: NewTraceExtractor(TraceparentFrom(key)),
+			ctx:       background,
+		},
+		{
+			name:      "unexpected type",
+			extractor: NewTraceExtractor(TraceparentFrom(key)),
+			ctx:       context.WithValue(background, key, 42),
+		},
+		{
+			name:      "invalid",
+			extractor: NewTraceExtractor(TraceparentFrom(key)),
+			ctx:       context.WithValue(background, key, "garbage"),
+		},
+		{
+			name:      "nil context",
+			extractor: NewTraceExtractor(TraceparentFrom(key)),
+		},
+		{
+			name:      "nil source",
+			extractor: NewTraceExtractor(nil),
+			ctx:       background,
+		},
+		{
+			name: "panicking source",
+			extractor: NewTraceExtractor(func(ctx context.Context) (TraceContext, bool) {
+				return TraceContext{TraceID: ctx.Value(key).(string)}, true
+			}),
+			ctx: background,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.extractor.Extract(tt.ctx))
+		})
+	}
+
+	t.Run("with the logger", func(t *testing.T) {
+		var buf logBuffer
+		l := NewWithConfig(NewConfig(buf.handler()).WithContextExtractor(NewTraceExtractor(TraceparentFrom(key)).Extract))
+		l.Info(context.WithValue(background, key, testTraceparent), "traced")
+		l.Info(background, "untraced")
+		records := buf.records(t)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", records[0]["trace_id"])
+		assert.NotContains(t, records[1], "trace_id")
+	})
+}
@@ -0,0 +1,158 @@
+package sloggorm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// txInfo tracks a transaction started through the Plugin
+type txInfo struct {
+	id    string
+	ctx   context.Context
+	begin time.Time
+
+	mu         sync.Mutex
+	statements int
+	dbTime     time.Duration
+	savepoints int
+	ended      bool
+	prepared   time.Time // the start of the prepared statement in progress, see txConn.StmtContext
+
+	inflight *inflightEntry
+}
+
+// newTxID generates a random transaction ID
+func newTxID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+// addStatement accounts a statement to the transaction
+func (tx *txInfo) addStatement(elapsed time.Duration) {
+	tx.mu.Lock()
+	tx.statements++
+	tx.dbTime += elapsed
+	tx.mu.Unlock()
+}
+
+// startPrepared accounts the prepared statement in progress, if any, then starts the given one.
+//
+// The prepared statements of gorm are executed on the returned *sql.Stmt, out of reach, so they are timed until the end
+// of their callbacks, see Plugin.after, or until the commit or rollback of a default transaction.
+func (tx *txInfo) startPrepared(now time.Time) {
+	tx.mu.Lock()
+	tx.endPreparedLocked(now)
+	tx.prepared = now
+	tx.mu.Unlock()
+}
+
+// endPrepared accounts the prepared statement in progress, if any
+func (tx *txInfo) endPrepared(now time.Time) {
+	tx.mu.Lock()
+	tx.endPreparedLocked(now)
+	tx.mu.Unlock()
+}
+
+func (tx *txInfo) endPreparedLocked(now time.Time) {
+	if !tx.prepared.IsZero() {
+		tx.statements++
+		tx.dbTime += now.Sub(tx.prepared)
+		tx.prepared = time.Time{}
+	}
+}
+
+// end marks the transaction as ended, it returns false if it has already ended
+func (tx *txInfo) end() bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.ended {
+		return false
+	}
+	tx.ended = true
+	return true
+}
+
+// beginTx starts tracking a new transaction
+func (p *Plugin) beginTx(ctx context.Context) *txInfo {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tx := &txInfo{id: newTxID(), ctx: ctx, begin: time.Now()}
+	if p.inflight != nil {
+		tx.inflight = &inflightEntry{ctx: ctx, start: tx.begin, source: fileWithLineNum(), tx: tx}
+		p.inflight.add(tx.inflight)
+	}
+
+	l := p.logger
+	if l.traceTransactions && l.enabled(ctx, slog.LevelInfo) {
+		attrs := l.appendSource(l.txAttrs(tx), fileWithLineNum())
+		l.log(ctx, slog.LevelInfo, l.txBeginMsg, l.recordAttrs(ctx, attrs)...)
+	}
+	return tx
+}
+
+// endTx stops tracking a transaction on commit or rollback, logging its duration and statistics
+func (p *Plugin) endTx(tx *txInfo, rollback bool, err error) {
+	if !tx.end() {
+		// e.g. rollback after a failed commit
+		return
+	}
+	if tx.inflight != nil {
+		p.inflight.remove(tx.inflight)
+	}
+
+	l := p.logger
+	level, msg := slog.LevelInfo, l.txCommitMsg
+	if rollback {
+		msg = l.txRollbackMsg
+	}
+	if err != nil {
+		level = slog.LevelError
+	}
+	if (!l.traceTransactions && err == nil) || !l.enabled(tx.ctx, level) {
+		return
+	}
+
+	attrs := l.txAttrs(tx)
+	if l.durationKey != "" {
+		attrs = append(attrs, slog.Duration(l.durationKey, time.Since(tx.begin)))
+	}
+	tx.mu.Lock()
+	attrs = append(attrs, slog.Int("statements", tx.statements), slog.Duration("db_time", tx.dbTime))
+	if tx.savepoints > 0 {
+		attrs = append(attrs, slog.Int("savepoints", tx.savepoints))
+	}
+	tx.mu.Unlock()
+	attrs = l.appendSource(attrs, fileWithLineNum())
+	if err != nil && l.errorKey != "" {
+		attrs = append(attrs, slog.Any(l.errorKey, err))
+	}
+	l.log(tx.ctx, level, msg, l.recordAttrs(tx.ctx, attrs)...)
+}
+
+// savepoint tracks the savepoints of a transaction, logging the rollbacks to a savepoint
+func (p *Plugin) savepoint(tx *txInfo, name string, rollback bool) {
+	if !rollback {
+		tx.mu.Lock()
+		tx.savepoints++
+		tx.mu.Unlock()
+		return
+	}
+
+	l := p.logger
+	if l.traceTransactions && l.enabled(tx.ctx, slog.LevelInfo) {
+		attrs := l.appendSource(l.txAttrs(tx, slog.String("savepoint", name)), fileWithLineNum())
+		l.log(tx.ctx, slog.LevelInfo, l.txRollbackMsg, l.recordAttrs(tx.ctx, attrs)...)
+	}
+}
+
+// txAttrs returns the transaction ID attribute, if enabled, followed by the given attributes
+func (l *logger) txAttrs(tx *txInfo, attrs ...slog.Attr) []slog.Attr {
+	if l.txIDKey == "" {
+		return attrs
+	}
+	return append([]slog.Attr{slog.String(l.txIDKey, tx.id)}, attrs...)
+}
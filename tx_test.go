@@ -0,0 +1,188 @@
+package sloggorm
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestPlugin_transactions(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithTraceAll(true).WithTraceTransactions(true))
+	db := openTestDB(t, NewPlugin(l))
+	buf.Reset()
+
+	t.Run("commit with nested rollback", func(t *testing.T) {
+		_, _, line, _ := runtime.Caller(0)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&testCompany{Name: "gorm"}).Error; err != nil {
+				return err
+			}
+			_ = tx.Transaction(func(tx *gorm.DB) error {
+				tx.Create(&testCompany{Name: "rolled back"})
+				return errors.New("nested")
+			})
+			var count int64
+			return tx.Model(&testCompany{}).Count(&count).Error
+		})
+		require.NoError(t, err)
+
+		records := buf.records(t)
+		msgs := make([]any, len(records))
+		for i, r := range records {
+			msgs[i] = r["msg"]
+		}
+		assert.Equal(t, []any{
+			"Transaction BEGIN",
+			"Query OK",             // insert
+			"Query OK",             // savepoint
+			"Query OK",             // nested insert
+			"Transaction ROLLBACK", // to savepoint
+			"Query OK",             // rollback to savepoint
+			"Query OK",             // count
+			"Transaction COMMIT",
+		}, msgs)
+
+		txID := records[0]["tx_id"]
+		assert.Len(t, txID, 16)
+		for _, r := range records {
+			assert.Equal(t, txID, r["tx_id"], r)
+		}
+		assert.Contains(t, records[4]["savepoint"], "sp0x")
+
+		commit := records[7]
+		assert.Equal(t, "INFO", commit["level"])
+		assert.Equal(t, float64(5), commit["statements"])
+		assert.Equal(t, float64(1), commit["savepoints"])
+		assert.Greater(t, commit["duration"], commit["db_time"])
+		assert.Equal(t, "tx_test.go:"+strconv.Itoa(line+1), path.Base(commit["file"].(string)))
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		tx := db.Begin()
+		tx.Create(&testCompany{Name: "rolled back"})
+		sqlDB, err := tx.DB()
+		require.NoError(t, err)
+		assert.NotNil(t, sqlDB)
+		require.NoError(t, tx.Rollback().Error)
+		assert.Error(t, tx.Rollback().Error)
+
+		records := buf.records(t)
+		require.Len(t, records, 3)
+		assert.Equal(t, "Transaction ROLLBACK", records[2]["msg"])
+		assert.Equal(t, float64(1), records[2]["statements"])
+		assert.Equal(t, records[0]["tx_id"], records[1]["tx_id"])
+		assert.Equal(t, records[0]["tx_id"], records[2]["tx_id"])
+	})
+
+	t.Run("default transaction", func(t *testing.T) {
+		require.NoError(t, db.Create(&testCompany{Name: "default"}).Error)
+		records := buf.records(t)
+		require.Len(t, records, 3)
+		assert.Equal(t, "Transaction BEGIN", records[0]["msg"])
+		assert.Equal(t, "Transaction COMMIT", records[1]["msg"])
+		assert.Equal(t, "Query OK", records[2]["msg"])
+		assert.Equal(t, records[0]["tx_id"], records[2]["tx_id"])
+	})
+
+	t.Run("no transaction", func(t *testing.T) {
+		var companies []testCompany
+		require.NoError(t, db.Find(&companies).Error)
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.NotContains(t, records[0], "tx_id")
+	})
+}
+
+func TestPlugin_transactionsNotTraced(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithTraceAll(true))
+	db := openTestDB(t, NewPlugin(l))
+	buf.Reset()
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&testCompany{Name: "gorm"}).Error
+	}))
+	records := buf.records(t)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Query OK", records[0]["msg"])
+	assert.Contains(t, records[0], "tx_id")
+}
+
+func TestPlugin_transactionsPrepared(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithTraceAll(true).WithTraceTransactions(true))
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{PrepareStmt: true})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(NewPlugin(l)))
+	require.NoError(t, db.Session(&gorm.Session{Logger: db.Logger.LogMode(gormlogger.Silent)}).AutoMigrate(&testCompany{}))
+	buf.Reset()
+
+	// a statement taking a few ms, to tell its execution from the preparation
+	const slowQuery = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 20000) SELECT count(*) FROM c"
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&testCompany{Name: "gorm"}).Error; err != nil {
+			return err
+		}
+		_ = tx.Transaction(func(tx *gorm.DB) error {
+			tx.Create(&testCompany{Name: "rolled back"})
+			return errors.New("nested")
+		})
+		var count int64
+		return tx.Raw(slowQuery).Find(&count).Error
+	}))
+
+	records := buf.records(t)
+	require.Len(t, records, 8)
+	assert.Equal(t, "Transaction ROLLBACK", records[4]["msg"])
+	assert.Contains(t, records[4]["savepoint"], "sp0x")
+	slow, commit := records[6], records[7]
+	assert.Equal(t, "Transaction COMMIT", commit["msg"])
+	assert.Equal(t, float64(5), commit["statements"])
+	assert.Equal(t, float64(1), commit["savepoints"])
+	assert.Greater(t, commit["db_time"], slow["duration"].(float64)/2)
+	assert.Greater(t, commit["duration"], commit["db_time"])
+
+	t.Run("default transaction", func(t *testing.T) {
+		require.NoError(t, db.Create(&testCompany{Name: "default"}).Error)
+		records := buf.records(t)
+		require.Len(t, records, 3)
+		assert.Equal(t, "Transaction COMMIT", records[1]["msg"])
+		assert.Equal(t, float64(1), records[1]["statements"])
+		assert.Greater(t, records[1]["db_time"], float64(0))
+	})
+}
+
+func Test_parseSavepoint(t *testing.T) {
+	tests := []struct {
+		query        string
+		wantName     string
+		wantRollback bool
+		wantOk       bool
+	}{
+		{"SAVEPOINT sp1", "sp1", false, true},
+		{`savepoint "sp 2"`, "sp 2", false, true},
+		{"ROLLBACK TO SAVEPOINT sp1", "sp1", true, true},
+		{"ROLLBACK TO sp1", "sp1", true, true},
+		{"ROLLBACK", "", false, false},
+		{"RELEASE SAVEPOINT sp1", "", false, false},
+		{"SELECT 1", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			name, rollback, ok := parseSavepoint(tt.query)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantRollback, rollback)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
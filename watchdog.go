@@ -0,0 +1,119 @@
+package sloggorm
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// watchdog periodically warns about the statements and transactions running for too long
+type watchdog struct {
+	threshold time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// startWatchdog starts the watchdog goroutine, checking the in-flight entries at a quarter of the threshold
+func (p *Plugin) startWatchdog() {
+	w := &watchdog{
+		threshold: p.watchdogThreshold,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	p.watchdog = w
+
+	interval := w.threshold / 4
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case now := <-ticker.C:
+				p.checkInflight(now)
+			}
+		}
+	}()
+}
+
+// close stops the watchdog goroutine and waits for it to return
+func (w *watchdog) close() {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// checkInflight warns about the entries running longer than the threshold at the given time.
+// An entry is warned about again each time its elapsed time doubles.
+func (p *Plugin) checkInflight(now time.Time) {
+	threshold := p.watchdog.threshold
+	for _, e := range p.inflight.snapshot() {
+		if e.nextWarn == 0 {
+			e.nextWarn = threshold
+		}
+		elapsed := now.Sub(e.start)
+		if elapsed < e.nextWarn {
+			continue
+		}
+		for e.nextWarn <= elapsed {
+			e.nextWarn *= 2
+		}
+		p.warnRunning(e, elapsed)
+	}
+}
+
+// warnRunning logs a statement or transaction which is still running
+func (p *Plugin) warnRunning(e *inflightEntry, elapsed time.Duration) {
+	l := p.logger
+	if !l.enabled(e.ctx, slog.LevelWarn) {
+		return
+	}
+
+	if e.tx != nil {
+		attrs := l.txAttrs(e.tx)
+		if l.durationKey != "" {
+			attrs = append(attrs, slog.Duration(l.durationKey, elapsed))
+		}
+		e.tx.mu.Lock()
+		attrs = append(attrs, slog.Int("statements", e.tx.statements), slog.Duration("db_time", e.tx.dbTime))
+		e.tx.mu.Unlock()
+		attrs = l.appendSource(attrs, e.source)
+		l.log(e.ctx, slog.LevelWarn, l.txRunningMsg, l.recordAttrs(e.ctx, attrs)...)
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 6)
+	if l.durationKey != "" {
+		attrs = append(attrs, slog.Duration(l.durationKey, elapsed))
+	}
+	attrs = l.appendSource(attrs, e.source)
+	if l.operationKey != "" && e.operation != "" {
+		attrs = append(attrs, slog.String(l.operationKey, e.operation))
+	}
+	if l.tableKey != "" && e.table != "" {
+		attrs = append(attrs, slog.Any(l.tableKey, []string{e.table}))
+	}
+	q := e.query.Load()
+	if q != nil && q.tx != nil {
+		attrs = l.txAttrs(q.tx, attrs...)
+	}
+	if l.queryKey != "" && q != nil {
+		attrs = append(attrs, slog.String(l.queryKey, p.explain(q)))
+	}
+	l.log(e.ctx, slog.LevelWarn, l.runningMsg, l.recordAttrs(e.ctx, attrs)...)
+}
+
+// explain returns the SQL of a query with its params, unless the logger is set to parameterized queries
+func (p *Plugin) explain(q *inflightQuery) string {
+	if p.logger.parameterizedQueries || p.dialector == nil || len(q.args) == 0 {
+		return q.sql
+	}
+	return p.dialector.Explain(q.sql, q.args...)
+}
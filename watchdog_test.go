@@ -0,0 +1,107 @@
+package sloggorm
+
+import (
+	"path"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPlugin_WithWatchdog(t *testing.T) {
+	var buf logBuffer
+	l := NewWithConfig(NewConfig(buf.handler()).WithOperationKey("operation"))
+	p := NewPlugin(l).WithWatchdog(time.Minute)
+	db := openTestDB(t, p)
+	t.Cleanup(func() { _ = p.Close() })
+	buf.Reset()
+
+	t.Run("running statement", func(t *testing.T) {
+		var checks []int
+		require.NoError(t, db.Callback().Raw().Before("gorm:raw").Register("test:watchdog", func(db *gorm.DB) {
+			now := time.Now()
+			for _, offset := range []time.Duration{90 * time.Second, 100 * time.Second, 5 * time.Minute} {
+				p.checkInflight(now.Add(offset))
+				checks = append(checks, len(buf.records(t)))
+			}
+		}))
+		defer func() { _ = db.Callback().Raw().Remove("test:watchdog") }()
+
+		buf.Reset()
+		require.NoError(t, db.Exec("UPDATE test_users SET age = ? WHERE id = ?", 18, 1).Error)
+		// warned at 1m, then after 2m, not in between
+		assert.Equal(t, []int{1, 0, 1}, checks)
+	})
+
+	t.Run("warning attributes", func(t *testing.T) {
+		require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:watchdog", func(db *gorm.DB) {
+			// the SQL is not built yet
+			p.checkInflight(time.Now().Add(time.Hour))
+		}))
+		require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:watchdog_after", func(db *gorm.DB) {
+			// the SQL has been sent to the driver
+			p.checkInflight(time.Now().Add(3 * time.Hour))
+		}))
+		defer func() {
+			_ = db.Callback().Query().Remove("test:watchdog")
+			_ = db.Callback().Query().Remove("test:watchdog_after")
+		}()
+
+		buf.Reset()
+		_, _, line, _ := runtime.Caller(0)
+		var user testUser
+		db.Where("name = ?", "jinzhu").Find(&user)
+
+		records := buf.records(t)
+		require.Len(t, records, 2)
+		assert.Equal(t, "WARN", records[0]["level"])
+		assert.Equal(t, "Query RUNNING", records[0]["msg"])
+		assert.Equal(t, "SELECT", records[0]["operation"])
+		assert.Equal(t, "watchdog_test.go:"+strconv.Itoa(line+2), path.Base(records[0]["file"].(string)))
+		assert.NotContains(t, records[0], "query")
+		assert.Greater(t, records[0]["duration"], float64(time.Hour))
+		assert.Equal(t, "SELECT * FROM `test_users` WHERE name = \"jinzhu\"", records[1]["query"])
+	})
+
+	t.Run("open transaction", func(t *testing.T) {
+		tx := db.Begin()
+		tx.Create(&testCompany{Name: "gorm"})
+		buf.Reset()
+
+		p.checkInflight(time.Now().Add(2 * time.Minute))
+		records := buf.records(t)
+		require.Len(t, records, 1)
+		assert.Equal(t, "Transaction RUNNING", records[0]["msg"])
+		assert.Equal(t, float64(1), records[0]["statements"])
+		assert.Contains(t, records[0], "tx_id")
+
+		require.NoError(t, tx.Commit().Error)
+		p.checkInflight(time.Now().Add(time.Hour))
+		assert.Empty(t, buf.records(t))
+		assert.Empty(t, p.inflight.snapshot())
+	})
+}
+
+func TestPlugin_Close(t *testing.T) {
+	var buf logBuffer
+	p := NewPlugin(NewWithConfig(NewConfig(buf.handler()))).WithWatchdog(10 * time.Millisecond)
+	db := openTestDB(t, p)
+
+	tx := db.Begin()
+	assert.Eventually(t, func() bool {
+		return len(buf.records(t)) > 0
+	}, time.Second, 5*time.Millisecond)
+	tx.Rollback()
+
+	require.NoError(t, p.Close())
+	require.NoError(t, p.Close())
+	select {
+	case <-p.watchdog.done:
+	default:
+		t.Error("watchdog goroutine is still running")
+	}
+}